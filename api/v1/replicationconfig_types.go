@@ -2,6 +2,7 @@ package v1
 
 import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // ReplicationConfigSpec defines the desired state of ReplicationConfig
@@ -9,8 +10,112 @@ type ReplicationConfigSpec struct {
 	NamespaceSelector metav1.LabelSelector `json:"namespaceSelector,omitempty"`
 	TemplateValues    TemplateValues       `json:"templateValues,omitempty"`
 	Resources         []Resource           `json:"resources,omitempty"`
+	// Prune decides what happens to replicated resources that are no longer rendered by this ReplicationConfig,
+	// either because the template was removed or because the namespace no longer matches the namespaceSelector.
+	// Delete removes them from the namespace, while Orphan leaves them in place without an owner reference.
+	// +kubebuilder:validation:Enum=Delete;Orphan
+	// +kubebuilder:default=Delete
+	// +kubebuilder:validation:Optional
+	Prune PrunePolicy `json:"prune,omitempty"`
+	// DeletionPolicy decides what happens to the replicated resources when the ReplicationConfig is deleted.
+	// Delete removes them from all namespaces, while Orphan leaves them in place without an owner reference.
+	// +kubebuilder:validation:Enum=Delete;Orphan
+	// +kubebuilder:default=Delete
+	// +kubebuilder:validation:Optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+	// Suspend stops the replication of this ReplicationConfig without deleting it or its resources.
+	// All namespaces are synchronized again when it is cleared.
+	// +kubebuilder:validation:Optional
+	Suspend bool `json:"suspend,omitempty"`
+	// DryRun renders the resources and validates them with server-side dry-run requests in every namespace,
+	// recording what would be created or updated in status.dryRun instead of applying them.
+	// +kubebuilder:validation:Optional
+	DryRun bool `json:"dryRun,omitempty"`
+	// ApplyStrategy decides how resources are written to the namespaces.
+	// Update replaces the whole resource when it has changed, while ServerSideApply uses server-side apply
+	// with the "replicator" field manager, so only the fields in the template are owned by the replicator.
+	// +kubebuilder:validation:Enum=Update;ServerSideApply
+	// +kubebuilder:default=Update
+	// +kubebuilder:validation:Optional
+	ApplyStrategy ApplyStrategy `json:"applyStrategy,omitempty"`
+	// ForceConflicts takes ownership of fields owned by other field managers when using ServerSideApply,
+	// instead of failing on conflicts.
+	// +kubebuilder:validation:Optional
+	ForceConflicts bool `json:"forceConflicts,omitempty"`
+	// ConflictPolicy decides what happens when a rendered resource already exists in a namespace without being owned by this ReplicationConfig.
+	// Adopt takes over the resource, Skip leaves it untouched, and Fail fails the synchronization of the namespace.
+	// +kubebuilder:validation:Enum=Adopt;Skip;Fail
+	// +kubebuilder:default=Adopt
+	// +kubebuilder:validation:Optional
+	ConflictPolicy ConflictPolicy `json:"conflictPolicy,omitempty"`
+	// Concurrency is the number of namespaces synchronized at the same time, overriding the --namespace-workers flag.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Optional
+	Concurrency int `json:"concurrency,omitempty"`
+	// Rollout synchronizes changes to the namespaces in waves instead of all at once.
+	// +kubebuilder:validation:Optional
+	Rollout *Rollout `json:"rollout,omitempty"`
+}
+
+// Rollout synchronizes a change to the canary namespaces first, and then to the other namespaces in batches.
+// The next wave starts after the pause, as long as the number of failing namespaces stays within maxFailures.
+type Rollout struct {
+	// Canary selects the namespaces in the first wave.
+	// +kubebuilder:validation:Optional
+	Canary *metav1.LabelSelector `json:"canary,omitempty"`
+	// BatchSize is the number, or percentage, of the targeted namespaces in each wave after the canaries.
+	// +kubebuilder:default="25%"
+	// +kubebuilder:validation:XIntOrString
+	// +kubebuilder:validation:Optional
+	BatchSize *intstr.IntOrString `json:"batchSize,omitempty"`
+	// Pause is how long to wait after a wave before checking the failures and starting the next wave.
+	// +kubebuilder:default="1m"
+	// +kubebuilder:validation:Optional
+	Pause metav1.Duration `json:"pause,omitempty"`
+	// MaxFailures is the number, or percentage, of the rolled out namespaces allowed to fail before the rollout is halted.
+	// +kubebuilder:default=0
+	// +kubebuilder:validation:XIntOrString
+	// +kubebuilder:validation:Optional
+	MaxFailures *intstr.IntOrString `json:"maxFailures,omitempty"`
 }
 
+type RolloutPhase string
+
+const (
+	RolloutProgressing RolloutPhase = "Progressing"
+	RolloutHalted      RolloutPhase = "Halted"
+	RolloutComplete    RolloutPhase = "Complete"
+)
+
+type DeletionPolicy string
+
+const (
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	DeletionPolicyOrphan DeletionPolicy = "Orphan"
+)
+
+type ApplyStrategy string
+
+const (
+	ApplyStrategyUpdate          ApplyStrategy = "Update"
+	ApplyStrategyServerSideApply ApplyStrategy = "ServerSideApply"
+)
+
+type ConflictPolicy string
+
+const (
+	ConflictPolicyAdopt ConflictPolicy = "Adopt"
+	ConflictPolicySkip  ConflictPolicy = "Skip"
+	ConflictPolicyFail  ConflictPolicy = "Fail"
+)
+
+type PrunePolicy string
+
+const (
+	PruneDelete PrunePolicy = "Delete"
+	PruneOrphan PrunePolicy = "Orphan"
+)
+
 type Secret struct {
 	Name string `json:"name,omitempty"`
 	// Validate checks that the secret exists before the ReplicationConfig is accepted.
@@ -18,21 +123,166 @@ type Secret struct {
 	// +kubebuilder:default=true
 	// +kubebuilder:validation:Optional
 	Validate bool `json:"validate,omitempty"`
+	// Prefix is added to the name of every value loaded from the secret, e.g. "db_" loads the key password as db_password.
+	// +kubebuilder:validation:Optional
+	Prefix string `json:"prefix,omitempty"`
+	// Keys selects the keys to load from the secret, optionally under another name. All keys are loaded if it is empty.
+	// +kubebuilder:validation:Optional
+	Keys []SecretKey `json:"keys,omitempty"`
+}
+
+// SecretKey selects a key of a secret to load as a value.
+type SecretKey struct {
+	Key string `json:"key"`
+	// As is the name of the value, which defaults to the key. The prefix of the secret is added to it.
+	// +kubebuilder:validation:Optional
+	As string `json:"as,omitempty"`
+}
+
+type ConfigMap struct {
+	Name string `json:"name,omitempty"`
+	// Validate checks that the config map exists before the ReplicationConfig is accepted.
+	// Setting this to false explicitly marks the config map as eventually consistent during reconciliation for retry.
+	// +kubebuilder:default=true
+	// +kubebuilder:validation:Optional
+	Validate bool `json:"validate,omitempty"`
 }
 
 type Resource struct {
 	Template string `json:"template,omitempty"`
+	// NamespaceSelector limits the resource to the namespaces matching it, among the namespaces targeted by the ReplicationConfig.
+	// +kubebuilder:validation:Optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	// When is a template expression rendered with the values of the namespace, e.g. [[ eq .Values.env "prod" ]].
+	// The resource is only replicated to the namespaces where it renders true.
+	// +kubebuilder:validation:Optional
+	When string `json:"when,omitempty"`
 }
 
+// Condition types of a ReplicationConfig
+const (
+	// ConditionReady is true when the last full synchronization completed and no namespace is failing.
+	ConditionReady = "Ready"
+	// ConditionSynced is true when the last full synchronization completed, even if some namespaces failed.
+	ConditionSynced = "Synced"
+	// ConditionDegraded is true when one or more namespaces failed to synchronize.
+	ConditionDegraded = "Degraded"
+	// ConditionSuspended is true while replication is suspended with spec.suspend.
+	ConditionSuspended = "Suspended"
+	// ConditionTerminating is true while the replicated resources are removed or orphaned after the ReplicationConfig is deleted.
+	ConditionTerminating = "Terminating"
+)
+
 // ReplicationConfigStatus defines the observed state of ReplicationConfig
 type ReplicationConfigStatus struct {
 	SynchronizationTimestamp metav1.Time `json:"synchronizationTimestamp,omitempty"`
 	SynchronizationHash      string      `json:"synchronizationHash,omitempty"`
+	// ObservedGeneration is the generation of the spec that was last synchronized.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// TargetedNamespaces is the number of namespaces matching the namespaceSelector.
+	// +optional
+	TargetedNamespaces int `json:"targetedNamespaces"`
+	// ExcludedNamespaces lists the namespaces matching the namespaceSelector that opt out with the replicator.nais.io/exclude annotation.
+	ExcludedNamespaces []string `json:"excludedNamespaces,omitempty"`
+	// SucceededNamespaces is the number of targeted namespaces that were synchronized.
+	// +optional
+	SucceededNamespaces int `json:"succeededNamespaces"`
+	// FailedNamespaces is the number of targeted namespaces that failed to synchronize.
+	// +optional
+	FailedNamespaces int `json:"failedNamespaces"`
+	// IgnoredResources is the number of replicated resources left untouched because of the replicator.nais.io/ignore annotation.
+	// +optional
+	IgnoredResources int `json:"ignoredResources"`
+	// Ignored lists the replicated resources left untouched because of the replicator.nais.io/ignore annotation.
+	Ignored []ResourceReference `json:"ignored,omitempty"`
+	// Failures lists the namespaces that failed to synchronize, with the last error.
+	Failures []NamespaceFailure `json:"failures,omitempty"`
+	// DryRun lists what a synchronization would do in each namespace, when spec.dryRun is set.
+	DryRun []DryRunResult `json:"dryRun,omitempty"`
+	// Resources is the inventory of resources created by this ReplicationConfig, used to prune resources that are no longer rendered.
+	Resources []ResourceReference `json:"resources,omitempty"`
+	// Conflicts lists the rendered resources that are left untouched because they are owned by another ReplicationConfig.
+	Conflicts []ResourceConflict `json:"conflicts,omitempty"`
+	// Collisions lists the values set by more than one of spec.templateValues.values, the config maps and the secrets,
+	// where the one with the highest precedence is used.
+	Collisions []ValueCollision `json:"collisions,omitempty"`
+	// Rollout is the progress of the last rollout, when spec.rollout is set.
+	Rollout *RolloutStatus `json:"rollout,omitempty"`
+}
+
+// RolloutStatus is the progress of rolling out a change to the namespaces.
+type RolloutStatus struct {
+	// Hash is the synchronization hash of the change being rolled out.
+	Hash  string       `json:"hash"`
+	Phase RolloutPhase `json:"phase"`
+	// Wave is the number of waves that have been synchronized.
+	// +optional
+	Wave int `json:"wave"`
+	// TotalNamespaces is the number of targeted namespaces.
+	// +optional
+	TotalNamespaces int `json:"totalNamespaces"`
+	// Namespaces lists the namespaces the change has been rolled out to.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// NextWaveTime is when the failures are checked and the next wave is started.
+	NextWaveTime *metav1.Time `json:"nextWaveTime,omitempty"`
+	// Message tells why the rollout was halted.
+	Message string `json:"message,omitempty"`
+}
+
+// DryRunResult is what a synchronization would do in a namespace. Resources are listed as kind/name.
+type DryRunResult struct {
+	Namespace   string   `json:"namespace"`
+	WouldCreate []string `json:"wouldCreate,omitempty"`
+	WouldUpdate []string `json:"wouldUpdate,omitempty"`
+	// WouldSkip lists the resources that would be left untouched, because they exist without being owned by the ReplicationConfig
+	// or have the replicator.nais.io/ignore annotation.
+	WouldSkip []string `json:"wouldSkip,omitempty"`
+	// +optional
+	Unchanged int      `json:"unchanged"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+// ValueCollision is a value loaded from more than one source.
+type ValueCollision struct {
+	Key string `json:"key"`
+	// Sources are the sources setting the value, in order of precedence: "values", "configMap/<name>" or "secret/<name>".
+	Sources []string `json:"sources"`
+}
+
+// ResourceConflict is a rendered resource that is owned by another ReplicationConfig.
+type ResourceConflict struct {
+	ResourceReference `json:",inline"`
+	// Owner is the name of the ReplicationConfig owning the resource.
+	Owner string `json:"owner"`
+}
+
+type NamespaceFailure struct {
+	Namespace string `json:"namespace"`
+	Error     string `json:"error"`
+}
+
+type ResourceReference struct {
+	Namespace  string `json:"namespace"`
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 //+kubebuilder:resource:scope=Cluster,shortName=repconf
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Namespaces",type=integer,JSONPath=`.status.targetedNamespaces`
+//+kubebuilder:printcolumn:name="Succeeded",type=integer,JSONPath=`.status.succeededNamespaces`
+//+kubebuilder:printcolumn:name="Failed",type=integer,JSONPath=`.status.failedNamespaces`
+//+kubebuilder:printcolumn:name="Rollout",type=string,JSONPath=`.status.rollout.phase`,priority=1
+//+kubebuilder:printcolumn:name="Suspended",type=boolean,JSONPath=`.spec.suspend`
+//+kubebuilder:printcolumn:name="Synchronized",type=date,JSONPath=`.status.synchronizationTimestamp`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // ReplicationConfig is the Schema for the replicationconfigs API
 type ReplicationConfig struct {
@@ -53,9 +303,11 @@ type ReplicationConfigList struct {
 }
 
 type TemplateValues struct {
-	Values    map[string]string `json:"values,omitempty"`
-	Secrets   []Secret          `json:"secrets,omitempty"`
-	Namespace Namespace         `json:"namespace,omitempty"`
+	Values map[string]string `json:"values,omitempty"`
+	// ConfigMaps are loaded from the namespace of the replicator, for values that are not sensitive.
+	ConfigMaps []ConfigMap `json:"configMaps,omitempty"`
+	Secrets    []Secret    `json:"secrets,omitempty"`
+	Namespace  Namespace   `json:"namespace,omitempty"`
 }
 
 type Namespace struct {
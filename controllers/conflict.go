@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"fmt"
+
+	naisiov1 "nais/replicator/api/v1"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// operationResultSkipped is returned when a resource is left untouched, e.g. because it is not owned by the ReplicationConfig.
+// Skipped resources are not added to the inventory.
+const operationResultSkipped controllerutil.OperationResult = "skipped"
+
+// ownerConflictError is returned for a resource owned by another ReplicationConfig, which is never written regardless of the conflictPolicy.
+// Two ReplicationConfigs writing the same resource would otherwise overwrite each other on every synchronization.
+type ownerConflictError struct {
+	kind, name, namespace string
+	owner                 string
+}
+
+func (e *ownerConflictError) Error() string {
+	return fmt.Sprintf("resource %v/%v in namespace %q is owned by ReplicationConfig %q", e.kind, e.name, e.namespace, e.owner)
+}
+
+// replicationConfigOwner returns the name of the ReplicationConfig owning the resource, other than rc.
+func replicationConfigOwner(resource *unstructured.Unstructured, rc *naisiov1.ReplicationConfig) string {
+	for _, ref := range resource.GetOwnerReferences() {
+		gv, err := schema.ParseGroupVersion(ref.APIVersion)
+		if err != nil || gv.Group != naisiov1.GroupVersion.Group || ref.Kind != "ReplicationConfig" || ref.UID == rc.UID {
+			continue
+		}
+		return ref.Name
+	}
+	return ""
+}
+
+// resolveConflict decides whether an existing resource that is not owned by the ReplicationConfig is written, according to its conflictPolicy.
+// With the Fail policy the conflict is returned as an error.
+func (r *ReplicationConfigReconciler) resolveConflict(rc *naisiov1.ReplicationConfig, existing *unstructured.Unstructured, dryRun bool) (bool, error) {
+	kind, name, namespace := existing.GetKind(), existing.GetName(), existing.GetNamespace()
+	if owner := replicationConfigOwner(existing, rc); owner != "" {
+		if !dryRun {
+			r.Recorder.Eventf(rc, "Warning", "Conflict", "Resource %v/%v in namespace %q is owned by ReplicationConfig %q, leaving it untouched", kind, name, namespace, owner)
+		}
+		return false, &ownerConflictError{kind: kind, name: name, namespace: namespace, owner: owner}
+	}
+
+	owners := "no owner"
+	if refs := existing.GetOwnerReferences(); len(refs) > 0 {
+		owners = fmt.Sprintf("owner %s %q", refs[0].Kind, refs[0].Name)
+	}
+
+	switch rc.Spec.ConflictPolicy {
+	case naisiov1.ConflictPolicySkip:
+		if !dryRun {
+			log.Infof("skipping resource %s%q in namespace %q, it exists with %s", kind, name, namespace, owners)
+			r.Recorder.Eventf(rc, "Warning", "Conflict", "Skipped resource %v/%v in namespace %q, it exists with %s", kind, name, namespace, owners)
+		}
+		return false, nil
+	case naisiov1.ConflictPolicyFail:
+		if !dryRun {
+			r.Recorder.Eventf(rc, "Warning", "Conflict", "Resource %v/%v in namespace %q exists with %s", kind, name, namespace, owners)
+		}
+		return false, fmt.Errorf("resource %v/%v in namespace %q exists with %s", kind, name, namespace, owners)
+	default:
+		if !dryRun {
+			log.Infof("adopting resource %s%q in namespace %q, it exists with %s", kind, name, namespace, owners)
+			r.Recorder.Eventf(rc, "Normal", "Adopted", "Adopted resource %v/%v in namespace %q that existed with %s", kind, name, namespace, owners)
+		}
+		return true, nil
+	}
+}
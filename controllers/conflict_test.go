@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	naisiov1 "nais/replicator/api/v1"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func TestWriteResourceConflicts(t *testing.T) {
+	other := &naisiov1.ReplicationConfig{ObjectMeta: metav1.ObjectMeta{Name: "other", UID: types.UID("other-uid")}}
+
+	for _, tt := range []struct {
+		name    string
+		policy  naisiov1.ConflictPolicy
+		owners  []metav1.OwnerReference
+		result  controllerutil.OperationResult
+		err     bool
+		adopted bool
+	}{
+		{name: "adopt", policy: naisiov1.ConflictPolicyAdopt, result: controllerutil.OperationResultUpdated, adopted: true},
+		{name: "adopt by default", result: controllerutil.OperationResultUpdated, adopted: true},
+		{name: "skip", policy: naisiov1.ConflictPolicySkip, result: operationResultSkipped},
+		{name: "fail", policy: naisiov1.ConflictPolicyFail, result: operationResultSkipped, err: true},
+		{name: "owned by another ReplicationConfig", policy: naisiov1.ConflictPolicyAdopt, owners: ownerReferences(other), result: operationResultSkipped, err: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := &naisiov1.ReplicationConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "rc", UID: types.UID("rc-uid")},
+				Spec:       naisiov1.ReplicationConfigSpec{ConflictPolicy: tt.policy},
+			}
+			existing := &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "team", Namespace: "team", OwnerReferences: tt.owners},
+				Data:       map[string]string{"owner": "team"},
+			}
+			r := newReconciler(t, interceptor.Funcs{}, rc, existing)
+			ctx := context.Background()
+
+			rendered := &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "team", Namespace: "team", OwnerReferences: ownerReferences(rc)},
+				Data:       map[string]string{"owner": "replicator"},
+			}
+			rendered.SetGroupVersionKind(v1.SchemeGroupVersion.WithKind("ConfigMap"))
+
+			result, err := r.writeResource(ctx, rc, toUnstructured(t, rendered), false)
+			assert.Equal(t, tt.result, result)
+			if tt.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			var ownerErr *ownerConflictError
+			assert.Equal(t, tt.owners != nil, errors.As(err, &ownerErr))
+
+			written := &v1.ConfigMap{}
+			assert.NoError(t, r.Get(ctx, client.ObjectKey{Name: "team", Namespace: "team"}, written))
+			if tt.adopted {
+				assert.Equal(t, "replicator", written.Data["owner"])
+				assert.True(t, isOwnedBy(toUnstructured(t, written), rc))
+			} else {
+				assert.Equal(t, "team", written.Data["owner"])
+				assert.Equal(t, tt.owners, written.OwnerReferences)
+			}
+		})
+	}
+}
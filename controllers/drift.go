@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"context"
+	"maps"
+	"reflect"
+
+	naisiov1 "nais/replicator/api/v1"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// driftPredicate passes updates and deletions of replicated resources.
+// Creations are our own, or seen when the watch is started, and are ignored.
+// Kinds that track their generation are only passed when the generation or metadata changes, to skip status updates.
+var driftPredicate = predicate.Funcs{
+	CreateFunc: func(event.CreateEvent) bool {
+		return false
+	},
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		if e.ObjectNew.GetGeneration() == 0 {
+			return e.ObjectOld.GetResourceVersion() != e.ObjectNew.GetResourceVersion()
+		}
+		return e.ObjectOld.GetGeneration() != e.ObjectNew.GetGeneration() ||
+			!maps.Equal(e.ObjectOld.GetLabels(), e.ObjectNew.GetLabels()) ||
+			!maps.Equal(e.ObjectOld.GetAnnotations(), e.ObjectNew.GetAnnotations()) ||
+			!reflect.DeepEqual(e.ObjectOld.GetOwnerReferences(), e.ObjectNew.GetOwnerReferences())
+	},
+	GenericFunc: func(event.GenericEvent) bool {
+		return false
+	},
+}
+
+// watchResource starts a metadata-only watch for the given kind, so that changes to replicated resources are corrected right away.
+// Kinds are watched at most once.
+func (r *ReplicationConfigReconciler) watchResource(gvk schema.GroupVersionKind) error {
+	r.watchedMu.Lock()
+	defer r.watchedMu.Unlock()
+
+	if r.controller == nil || r.watched[gvk] {
+		return nil
+	}
+
+	obj := &metav1.PartialObjectMetadata{}
+	obj.SetGroupVersionKind(gvk)
+	err := r.controller.Watch(source.Kind[client.Object](r.cache, obj, handler.EnqueueRequestsFromMapFunc(ownerRequests), driftPredicate))
+	if err != nil {
+		return err
+	}
+	r.watched[gvk] = true
+	return nil
+}
+
+func (r *ReplicationConfigReconciler) watchInventory(refs []naisiov1.ResourceReference) {
+	for _, ref := range refs {
+		gvk := schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind)
+		if err := r.watchResource(gvk); err != nil {
+			log.Warnf("unable to watch %v for drift: %v", gvk, err)
+		}
+	}
+}
+
+// ownerRequests maps a replicated resource to a request for its owning ReplicationConfig, limited to the namespace of the resource.
+func ownerRequests(_ context.Context, obj client.Object) []reconcile.Request {
+	var requests []reconcile.Request
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.APIVersion == naisiov1.GroupVersion.String() && ref.Kind == "ReplicationConfig" {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: ref.Name, Namespace: obj.GetNamespace()}})
+		}
+	}
+	return requests
+}
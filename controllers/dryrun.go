@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	naisiov1 "nais/replicator/api/v1"
+	"nais/replicator/internal/replicator"
+
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// dryRun renders the resources for every namespace and validates them with server-side dry-run requests,
+// returning what a synchronization would do without changing anything.
+func (r *ReplicationConfigReconciler) dryRun(ctx context.Context, rc *naisiov1.ReplicationConfig, namespaces []v1.Namespace, scope replicator.Scope) []naisiov1.DryRunResult {
+	results := make([]naisiov1.DryRunResult, 0, len(namespaces))
+	for _, ns := range namespaces {
+		result := naisiov1.DryRunResult{Namespace: ns.Name}
+
+		resources, err := r.renderNamespace(rc, ns, scope)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("rendering resources: %v", err))
+			results = append(results, result)
+			continue
+		}
+
+		for _, resource := range resources {
+			name := resource.GetKind() + "/" + resource.GetName()
+			op, err := r.writeResource(ctx, rc, resource, true)
+			switch {
+			case err != nil:
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", name, err))
+			case op == controllerutil.OperationResultCreated:
+				result.WouldCreate = append(result.WouldCreate, name)
+			case op == controllerutil.OperationResultUpdated:
+				result.WouldUpdate = append(result.WouldUpdate, name)
+			case op == operationResultSkipped, op == operationResultIgnored:
+				result.WouldSkip = append(result.WouldSkip, name)
+			default:
+				result.Unchanged++
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	naisiov1 "nais/replicator/api/v1"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// finalizer makes sure the replicated resources are handled according to the deletion policy before the ReplicationConfig is gone.
+const finalizer = "replicator.nais.io/finalizer"
+
+// finalizeBatchSize is the number of resources removed between each status update, to report progress.
+const finalizeBatchSize = 50
+
+// finalize removes or orphans every resource in the inventory according to the deletion policy, and then removes the finalizer.
+// Resources that could not be removed are kept in the inventory, and the deletion is retried.
+func (r *ReplicationConfigReconciler) finalize(ctx context.Context, rc *naisiov1.ReplicationConfig) error {
+	if !controllerutil.ContainsFinalizer(rc, finalizer) {
+		return nil
+	}
+
+	orphan := rc.Spec.DeletionPolicy == naisiov1.DeletionPolicyOrphan
+	action := "Deleting"
+	if orphan {
+		action = "Orphaning"
+	}
+	log.Infof("%s %d resources of deleted %s%q", action, len(rc.Status.Resources), rc.Kind, rc.Name)
+
+	var remaining []naisiov1.ResourceReference
+	var errs int
+	refs := rc.Status.Resources
+	for len(refs) > 0 {
+		batch := refs[:min(finalizeBatchSize, len(refs))]
+		refs = refs[len(batch):]
+
+		for _, ref := range batch {
+			if _, err := r.removeResource(ctx, rc, ref, orphan); err != nil {
+				log.Warnf("unable to remove resource %s%q in namespace %q: %v", ref.Kind, ref.Name, ref.Namespace, err)
+				remaining = append(remaining, ref)
+				errs++
+			}
+		}
+
+		inventory := append(append([]naisiov1.ResourceReference{}, remaining...), refs...)
+		err := r.updateStatus(ctx, rc.Name, func(rc *naisiov1.ReplicationConfig) {
+			rc.Status.Resources = inventory
+			meta.SetStatusCondition(&rc.Status.Conditions, metav1.Condition{
+				Type:               naisiov1.ConditionTerminating,
+				Status:             metav1.ConditionTrue,
+				Reason:             action,
+				Message:            fmt.Sprintf("%s replicated resources, %d remaining", action, len(inventory)),
+				ObservedGeneration: rc.Generation,
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if errs > 0 {
+		r.Recorder.Eventf(rc, "Warning", "Finalize", "Unable to remove %d resources, retrying", errs)
+		return fmt.Errorf("unable to remove %d resources of %q", errs, rc.Name)
+	}
+
+	// the status updates above have changed the resourceVersion of rc, so the finalizer is removed with a patch
+	patch := client.MergeFrom(rc.DeepCopy())
+	controllerutil.RemoveFinalizer(rc, finalizer)
+	if err := r.Patch(ctx, rc, patch); err != nil {
+		return fmt.Errorf("removing finalizer: %w", err)
+	}
+	log.Infof("finalized deleted %s%q", rc.Kind, rc.Name)
+	return nil
+}
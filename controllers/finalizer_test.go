@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	naisiov1 "nais/replicator/api/v1"
+	"nais/replicator/internal/replicator"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestFinalize(t *testing.T) {
+	now := metav1.Now()
+	rc := &naisiov1.ReplicationConfig{ObjectMeta: metav1.ObjectMeta{
+		Name:              "deleted",
+		UID:               types.UID("deleted-uid"),
+		DeletionTimestamp: &now,
+		Finalizers:        []string{finalizer},
+	}}
+
+	// more resources than fit in a batch, so the status is updated in between
+	objects := []client.Object{rc, &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team"}}}
+	for i := range finalizeBatchSize + 10 {
+		cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name:            fmt.Sprintf("cm-%03d", i),
+			Namespace:       "team",
+			OwnerReferences: ownerReferences(rc),
+		}}
+		cm.SetGroupVersionKind(v1.SchemeGroupVersion.WithKind("ConfigMap"))
+		objects = append(objects, cm)
+		rc.Status.Resources = append(rc.Status.Resources, replicator.Reference(toUnstructured(t, cm)))
+	}
+
+	failing := true
+	r := newReconciler(t, interceptor.Funcs{
+		Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+			if failing && obj.GetName() == "cm-001" {
+				return errors.New("delete failed")
+			}
+			return c.Delete(ctx, obj, opts...)
+		},
+	}, objects...)
+	ctx := context.Background()
+
+	// resources that could not be removed are kept in the inventory, along with the finalizer
+	assert.Error(t, r.finalize(ctx, getReplicationConfig(t, r, rc.Name)))
+	deleted := getReplicationConfig(t, r, rc.Name)
+	assert.Equal(t, []naisiov1.ResourceReference{rc.Status.Resources[1]}, deleted.Status.Resources)
+	assert.Equal(t, []string{finalizer}, deleted.Finalizers)
+	assert.NoError(t, r.Get(ctx, client.ObjectKey{Name: "cm-001", Namespace: "team"}, &v1.ConfigMap{}))
+	assert.True(t, apierrors.IsNotFound(r.Get(ctx, client.ObjectKey{Name: "cm-000", Namespace: "team"}, &v1.ConfigMap{})))
+
+	// the finalizer is removed once the remaining resource is removed, which deletes the ReplicationConfig
+	failing = false
+	assert.NoError(t, r.finalize(ctx, deleted))
+	assert.True(t, apierrors.IsNotFound(r.Get(ctx, client.ObjectKey{Name: rc.Name}, &naisiov1.ReplicationConfig{})))
+	assert.True(t, apierrors.IsNotFound(r.Get(ctx, client.ObjectKey{Name: "cm-001", Namespace: "team"}, &v1.ConfigMap{})))
+}
+
+func TestFinalizeRemovesFinalizerAfterStatusUpdates(t *testing.T) {
+	now := metav1.Now()
+	rc := &naisiov1.ReplicationConfig{ObjectMeta: metav1.ObjectMeta{
+		Name:              "deleted",
+		UID:               types.UID("deleted-uid"),
+		DeletionTimestamp: &now,
+		Finalizers:        []string{finalizer, "other"},
+	}}
+	rc.Status.Resources = []naisiov1.ResourceReference{{Namespace: "team", APIVersion: "v1", Kind: "ConfigMap", Name: "gone"}}
+	r := newReconciler(t, interceptor.Funcs{}, rc)
+	ctx := context.Background()
+
+	// the object passed to finalize is stale after the status update of the first batch
+	assert.NoError(t, r.finalize(ctx, getReplicationConfig(t, r, rc.Name)))
+	deleted := getReplicationConfig(t, r, rc.Name)
+	assert.Equal(t, []string{"other"}, deleted.Finalizers)
+	assert.Empty(t, deleted.Status.Resources)
+}
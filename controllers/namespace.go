@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	naisiov1 "nais/replicator/api/v1"
+	"nais/replicator/internal/replicator"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// excludeAnnotation on a namespace lists the ReplicationConfigs, separated by commas, that should not replicate to it.
+// "*" excludes the namespace from every ReplicationConfig.
+const excludeAnnotation = "replicator.nais.io/exclude"
+
+// namespaceRequests maps a namespace event to a request per ReplicationConfig that either selects the namespace or has resources in it.
+// The namespace is set on the request, so only that namespace is reconciled.
+func (r *ReplicationConfigReconciler) namespaceRequests(ctx context.Context, obj client.Object) []reconcile.Request {
+	var rcs naisiov1.ReplicationConfigList
+	if err := r.List(ctx, &rcs); err != nil {
+		log.Errorf("listing ReplicationConfigs for namespace %q: %v", obj.GetName(), err)
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, rc := range rcs.Items {
+		selected, err := selects(&rc.Spec.NamespaceSelector, obj.GetLabels())
+		if err != nil {
+			log.Warnf("invalid namespaceSelector in %q: %v", rc.Name, err)
+			continue
+		}
+		if selected || len(replicator.InNamespace(rc.Status.Resources, obj.GetName())) > 0 {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: rc.Name, Namespace: obj.GetName()}})
+		}
+	}
+	return requests
+}
+
+// createdAfter passes create events of objects created after the given time, and every other event.
+// The informer delivers a create event for every existing namespace when it starts, and those are covered by the full synchronization.
+func createdAfter(t time.Time) predicate.Funcs {
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			// creation timestamps only have second precision
+			return !e.Object.GetCreationTimestamp().Time.Before(t.Truncate(time.Second))
+		},
+	}
+}
+
+// reconcileNamespace synchronizes a single namespace, e.g. when it is created or relabeled.
+// The synchronization hash and timestamp are left untouched, as this is not a full synchronization.
+func (r *ReplicationConfigReconciler) reconcileNamespace(ctx context.Context, rc *naisiov1.ReplicationConfig, namespace string) error {
+	// nothing is applied during a dry run, the full synchronization covers every namespace
+	if rc.Spec.DryRun {
+		return nil
+	}
+
+	previous := replicator.InNamespace(rc.Status.Resources, namespace)
+
+	ns := &v1.Namespace{}
+	err := r.Get(ctx, client.ObjectKey{Name: namespace}, ns)
+	if client.IgnoreNotFound(err) != nil {
+		return err
+	}
+
+	// resources in a deleted namespace are deleted along with it
+	if apierrors.IsNotFound(err) {
+		return r.updateNamespaceStatus(ctx, rc.Name, namespace, syncResult{}, nil)
+	}
+
+	selected, err := selects(&rc.Spec.NamespaceSelector, ns.Labels)
+	if err != nil {
+		return err
+	}
+	selected = selected && !excludes(ns.Annotations, rc.Name)
+
+	if !selected {
+		// the namespace may have been excluded with the annotation, which is reported in the status
+		if len(previous) == 0 {
+			return r.updateNamespaceStatus(ctx, rc.Name, namespace, syncResult{}, nil)
+		}
+		if err := r.pruneNamespace(ctx, rc, namespace, previous); err != nil {
+			r.Recorder.Eventf(rc, "Warning", "PruneNamespace", "Unable to prune resources in namespace %q: %v", namespace, err)
+			return err
+		}
+		return r.updateNamespaceStatus(ctx, rc.Name, namespace, syncResult{}, nil)
+	}
+
+	log.Debugf("reconciling %s%q to namespace %q", rc.Kind, rc.Name, namespace)
+
+	configMaps, err := replicator.LoadConfigMaps(ctx, r.Client, rc)
+	if err != nil {
+		return err
+	}
+
+	secrets, err := replicator.LoadSecrets(ctx, r.Client, rc)
+	if err != nil {
+		return err
+	}
+
+	// collisions between the sources are recorded by the full synchronization
+	values, _ := replicator.MergeValues(rc, configMaps, secrets)
+	hash, err := replicator.Hash(&rc.Spec, values)
+	if err != nil {
+		return err
+	}
+	if !rolledOut(rc, hash, namespace) {
+		log.Debugf("holding back %s%q from namespace %q until it is rolled out", rc.Kind, rc.Name, namespace)
+		return nil
+	}
+
+	scope := replicator.NewScope(values)
+
+	result, err := r.syncNamespace(ctx, rc, *ns, scope)
+	if err != nil {
+		if statusErr := r.updateNamespaceStatus(ctx, rc.Name, namespace, syncResult{
+			resources: previous,
+			conflicts: conflictsInNamespace(rc.Status.Conflicts, namespace),
+			ignored:   replicator.InNamespace(rc.Status.Ignored, namespace),
+		}, err); statusErr != nil {
+			log.Errorf("unable to record failure of namespace %q: %v", namespace, statusErr)
+		}
+		return err
+	}
+
+	// resources in the inventory that had to be changed have drifted from the rendered templates
+	for _, ref := range result.changed {
+		if slices.Contains(previous, ref) {
+			log.Infof("corrected drift of resource %s%q in namespace %q", ref.Kind, ref.Name, ref.Namespace)
+			r.Recorder.Eventf(rc, "Normal", "DriftCorrected", "Reapplied resource %v/%v in namespace %q that had drifted from the rendered template", ref.Kind, ref.Name, ref.Namespace)
+		}
+	}
+
+	for _, ref := range replicator.Stale(previous, result.resources) {
+		if err := r.prune(ctx, rc, ref); err != nil {
+			r.Recorder.Eventf(rc, "Warning", "Prune", "Unable to prune resource %v/%v for namespace %q: %v", ref.Kind, ref.Name, ref.Namespace, err)
+			return err
+		}
+	}
+
+	return r.updateNamespaceStatus(ctx, rc.Name, namespace, result, nil)
+}
+
+// updateNamespaceStatus replaces the inventory entries, conflicts and failure of a single namespace in the status of the ReplicationConfig.
+func (r *ReplicationConfigReconciler) updateNamespaceStatus(ctx context.Context, name, namespace string, result syncResult, syncErr error) error {
+	return r.updateStatus(ctx, name, func(rc *naisiov1.ReplicationConfig) {
+		// the namespace may have started or stopped matching the namespaceSelector
+		if namespaces, excluded, err := r.listNamespaces(ctx, rc); err == nil {
+			rc.Status.TargetedNamespaces = len(namespaces.Items)
+			rc.Status.ExcludedNamespaces = excluded
+		}
+		rc.Status.Resources = replicator.ReplaceNamespace(rc.Status.Resources, namespace, result.resources)
+		setNamespaceConflicts(&rc.Status, namespace, result.conflicts)
+		rc.Status.Ignored = replicator.ReplaceNamespace(rc.Status.Ignored, namespace, result.ignored)
+		setNamespaceFailure(&rc.Status, namespace, syncErr)
+		setConditions(rc)
+	})
+}
+
+func selects(ls *metav1.LabelSelector, l map[string]string) (bool, error) {
+	selector, err := metav1.LabelSelectorAsSelector(ls)
+	if err != nil {
+		return false, err
+	}
+	return selector.Matches(labels.Set(l)), nil
+}
+
+// excludes reports whether the exclude annotation of a namespace opts out of the named ReplicationConfig.
+func excludes(annotations map[string]string, name string) bool {
+	value, ok := annotations[excludeAnnotation]
+	if !ok {
+		return false
+	}
+	for _, excluded := range strings.Split(value, ",") {
+		excluded = strings.TrimSpace(excluded)
+		if excluded == "*" || excluded == name {
+			return true
+		}
+	}
+	return false
+}
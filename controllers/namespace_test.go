@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestCreatedAfter(t *testing.T) {
+	started := time.Now()
+	p := createdAfter(started)
+
+	existing := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "existing", CreationTimestamp: metav1.NewTime(started.Add(-time.Hour))}}
+	created := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "created", CreationTimestamp: metav1.NewTime(started.Add(time.Minute))}}
+
+	assert.False(t, p.Create(event.CreateEvent{Object: existing}))
+	assert.True(t, p.Create(event.CreateEvent{Object: created}))
+	assert.True(t, p.Update(event.UpdateEvent{ObjectOld: existing, ObjectNew: existing}))
+	assert.True(t, p.Delete(event.DeleteEvent{Object: existing}))
+}
@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	naisiov1 "nais/replicator/api/v1"
+	"nais/replicator/internal/replicator"
+
+	log "github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// prune removes a resource that is no longer rendered by the ReplicationConfig, or orphans it if the prune policy says so.
+func (r *ReplicationConfigReconciler) prune(ctx context.Context, rc *naisiov1.ReplicationConfig, ref naisiov1.ResourceReference) error {
+	orphan := rc.Spec.Prune == naisiov1.PruneOrphan
+	removed, err := r.removeResource(ctx, rc, ref, orphan)
+	if err != nil || !removed {
+		return err
+	}
+
+	if orphan {
+		r.Recorder.Eventf(rc, "Normal", "Prune", "Orphaned resource %v/%v in namespace %q", ref.Kind, ref.Name, ref.Namespace)
+	} else {
+		r.Recorder.Eventf(rc, "Normal", "Prune", "Deleted resource %v/%v in namespace %q", ref.Kind, ref.Name, ref.Namespace)
+	}
+	return nil
+}
+
+// removeResource deletes a replicated resource, or orphans it by removing the owner reference to the ReplicationConfig.
+// Resources that are gone or no longer owned by the ReplicationConfig are left untouched, and false is returned.
+func (r *ReplicationConfigReconciler) removeResource(ctx context.Context, rc *naisiov1.ReplicationConfig, ref naisiov1.ResourceReference, orphan bool) (bool, error) {
+	resource := replicator.Object(ref)
+	err := r.Get(ctx, client.ObjectKeyFromObject(resource), resource)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if !isOwnedBy(resource, rc) {
+		log.Infof("resource %s%q in namespace %q is not owned by %q, leaving it as is", ref.Kind, ref.Name, ref.Namespace, rc.Name)
+		return false, nil
+	}
+
+	if orphan {
+		return true, r.orphanResource(ctx, rc, resource)
+	}
+
+	err = r.Delete(ctx, resource)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("deleting resource: %w", err)
+	}
+	log.Infof("deleted resource %s%q in namespace %q", ref.Kind, ref.Name, ref.Namespace)
+	return true, nil
+}
+
+// pruneNamespace prunes all resources in a namespace that previously received resources, but no longer matches the namespaceSelector.
+func (r *ReplicationConfigReconciler) pruneNamespace(ctx context.Context, rc *naisiov1.ReplicationConfig, namespace string, refs []naisiov1.ResourceReference) error {
+	action := "Deleting"
+	if rc.Spec.Prune == naisiov1.PruneOrphan {
+		action = "Orphaning"
+	}
+	log.Infof("namespace %q no longer matches the namespaceSelector of %q, pruning %d resources", namespace, rc.Name, len(refs))
+	r.Recorder.Eventf(rc, "Normal", "PruneNamespace", "Namespace %q no longer matches the namespaceSelector. %s %d resources", namespace, action, len(refs))
+
+	for _, ref := range refs {
+		if err := r.prune(ctx, rc, ref); err != nil {
+			return fmt.Errorf("pruning resource %v/%v: %w", ref.Kind, ref.Name, err)
+		}
+	}
+	return nil
+}
+
+// orphanResource removes the owner reference to the ReplicationConfig, leaving the resource in place.
+func (r *ReplicationConfigReconciler) orphanResource(ctx context.Context, rc *naisiov1.ReplicationConfig, resource *unstructured.Unstructured) error {
+	var ownerRefs []metav1.OwnerReference
+	for _, ref := range resource.GetOwnerReferences() {
+		if ref.UID != rc.UID {
+			ownerRefs = append(ownerRefs, ref)
+		}
+	}
+	resource.SetOwnerReferences(ownerRefs)
+
+	if err := r.Update(ctx, resource); err != nil {
+		return fmt.Errorf("orphaning resource: %w", err)
+	}
+	log.Infof("orphaned resource %s%q in namespace %q", resource.GetKind(), resource.GetName(), resource.GetNamespace())
+	return nil
+}
+
+func isOwnedBy(resource *unstructured.Unstructured, rc *naisiov1.ReplicationConfig) bool {
+	for _, ref := range resource.GetOwnerReferences() {
+		if ref.UID == rc.UID {
+			return true
+		}
+	}
+	return false
+}
@@ -2,8 +2,10 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"sync"
 	"time"
 
 	"nais/replicator/internal/content"
@@ -15,6 +17,7 @@ import (
 
 	log "github.com/sirupsen/logrus"
 
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/client-go/tools/record"
 
@@ -23,29 +26,113 @@ import (
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
+// fieldManager is the field manager used with server-side apply.
+const fieldManager = "replicator"
+
+// ignoreAnnotation set to "true" on a replicated resource stops the replicator from updating it, e.g. while it is tuned by hand.
+const ignoreAnnotation = "replicator.nais.io/ignore"
+
+// operationResultIgnored is returned when an existing resource is left untouched because of the ignore annotation.
+// Ignored resources stay in the inventory.
+const operationResultIgnored controllerutil.OperationResult = "ignored"
+
 type ReplicationConfigReconciler struct {
 	client.Client
 	Scheme       *runtime.Scheme
 	Recorder     record.EventRecorder
 	SyncInterval time.Duration
+	// NamespaceWorkers is the number of namespaces synchronized at the same time by a full synchronization.
+	NamespaceWorkers int
+	// MaxConcurrentReconciles is the number of ReplicationConfigs reconciled at the same time.
+	// Reconciles of the same ReplicationConfig, including those of a single namespace, never run at the same time.
+	MaxConcurrentReconciles int
+
+	controller controller.Controller
+	cache      cache.Cache
+	watchedMu  sync.Mutex
+	watched    map[schema.GroupVersionKind]bool
+	retries    chan event.TypedGenericEvent[reconcile.Request]
+	// configLocks holds a *sync.Mutex per ReplicationConfig name, see lockConfig.
+	configLocks sync.Map
 }
 
 // +kubebuilder:rbac:groups=nais.io,resources=replicationconfigs,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=nais.io,resources=replicationconfigs/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=nais.io,resources=replicationconfigs/finalizers,verbs=update
-// +kubebuilder:rbac:groups="*",resources=*,verbs=create;update;patch;get;list;watch
+// +kubebuilder:rbac:groups="*",resources=*,verbs=create;update;patch;delete;get;list;watch
 func (r *ReplicationConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	// ReplicationConfig is cluster scoped, so the namespace of the request is used to reconcile a single target namespace
+	defer r.lockConfig(req.Name)()
+
 	rc := &naisiov1.ReplicationConfig{}
-	err := r.Get(ctx, req.NamespacedName, rc)
+	err := r.Get(ctx, client.ObjectKey{Name: req.Name}, rc)
 	if err != nil {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	hash, err := replicator.Hash(&rc.Spec)
+	if !rc.DeletionTimestamp.IsZero() {
+		// namespaces are not synchronized while the resources are removed
+		if req.Namespace != "" {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, r.finalize(ctx, rc)
+	}
+
+	if controllerutil.AddFinalizer(rc, finalizer) {
+		if err := r.Update(ctx, rc); err != nil {
+			return ctrl.Result{}, fmt.Errorf("adding finalizer: %w", err)
+		}
+	}
+
+	if rc.Spec.Suspend {
+		if req.Namespace != "" {
+			return ctrl.Result{}, nil
+		}
+		log.Debugf("replication of %s%q is suspended", rc.Kind, rc.Name)
+		return ctrl.Result{}, r.updateStatus(ctx, req.Name, func(rc *naisiov1.ReplicationConfig) {
+			// clear the hash, so every namespace is synchronized when replication is resumed
+			rc.Status.SynchronizationHash = ""
+			setSuspended(rc)
+		})
+	}
+
+	// watches are registered when resources are applied, so also register the ones in the inventory after a restart
+	r.watchInventory(rc.Status.Resources)
+
+	if req.Namespace != "" {
+		return ctrl.Result{}, r.reconcileNamespace(ctx, rc, req.Namespace)
+	}
+
+	configMaps, err := replicator.LoadConfigMaps(ctx, r.Client, rc)
+	if err != nil {
+		return ctrl.Result{}, r.syncFailed(ctx, rc.Name, "LoadConfigMapsFailed", err)
+	}
+
+	secrets, err := replicator.LoadSecrets(ctx, r.Client, rc)
+	if err != nil {
+		return ctrl.Result{}, r.syncFailed(ctx, rc.Name, "LoadSecretsFailed", err)
+	}
+
+	values, collisions := replicator.MergeValues(rc, configMaps, secrets)
+	if err := r.recordCollisions(ctx, rc, collisions); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	hash, err := replicator.Hash(&rc.Spec, values)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
@@ -59,148 +146,449 @@ func (r *ReplicationConfigReconciler) Reconcile(ctx context.Context, req ctrl.Re
 		log.Debugf("reconciling: hash changed: %v, outside syncInterval window: %v", rc.Status.SynchronizationHash != hash, r.needsSync(rc.Status.SynchronizationTimestamp.Time))
 	}
 
-	namespaces, err := r.listNamespaces(ctx, &rc.Spec.NamespaceSelector)
+	namespaces, excluded, err := r.listNamespaces(ctx, rc)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
 	log.Debugf("reconciling %s%q to %d namespaces\n", rc.Kind, rc.Name, len(namespaces.Items))
 
-	secrets, err := replicator.LoadSecrets(ctx, r.Client, rc)
+	scope := replicator.NewScope(values)
+
+	if rc.Spec.DryRun {
+		results := r.dryRun(ctx, rc, namespaces.Items, scope)
+		log.Infof("finished dry run of %s%q to %d namespaces\n", rc.Kind, rc.Name, len(namespaces.Items))
+		return ctrl.Result{}, r.updateStatus(ctx, req.Name, func(rc *naisiov1.ReplicationConfig) {
+			rc.Status.SynchronizationTimestamp = metav1.Now()
+			rc.Status.SynchronizationHash = hash
+			rc.Status.TargetedNamespaces = len(namespaces.Items)
+			rc.Status.ExcludedNamespaces = excluded
+			rc.Status.DryRun = results
+			setSynced(rc, "DryRun", errors.New("dry run is enabled, resources are not applied"))
+		})
+	}
+
+	if rc.Spec.Rollout != nil && rc.Status.SynchronizationHash != hash {
+		result, done, err := r.rollout(ctx, rc, hash, namespaces.Items, scope)
+		if err != nil || !done {
+			return result, err
+		}
+	}
+
+	result, failures := r.syncNamespaces(ctx, rc, namespaces.Items, scope)
+	inventory := result.resources
+
+	// stale entries of the targeted namespaces are pruned when they are synchronized, so the rest are in namespaces that no longer match
+	unmatched := make(map[string][]naisiov1.ResourceReference)
+	for _, ref := range replicator.Stale(rc.Status.Resources, inventory) {
+		unmatched[ref.Namespace] = append(unmatched[ref.Namespace], ref)
+	}
+
+	for namespace, refs := range unmatched {
+		if err := r.pruneNamespace(ctx, rc, namespace, refs); err != nil {
+			r.Recorder.Eventf(rc, "Warning", "PruneNamespace", "Unable to prune resources in namespace %q: %v", namespace, err)
+			// keep the inventory of the namespace to retry on the next synchronization
+			inventory = append(inventory, refs...)
+		}
+	}
+	replicator.SortReferences(inventory)
+
+	err = r.updateStatus(ctx, req.Name, func(rc *naisiov1.ReplicationConfig) {
+		rc.Status.SynchronizationTimestamp = metav1.Now()
+		rc.Status.SynchronizationHash = hash
+		rc.Status.Resources = inventory
+		rc.Status.Conflicts = result.conflicts
+		rc.Status.Ignored = result.ignored
+		rc.Status.TargetedNamespaces = len(namespaces.Items)
+		rc.Status.ExcludedNamespaces = excluded
+		rc.Status.Failures = nil
+		rc.Status.DryRun = nil
+		if rc.Spec.Rollout == nil {
+			rc.Status.Rollout = nil
+		}
+		for namespace, err := range failures {
+			setNamespaceFailure(&rc.Status, namespace, err)
+		}
+		setSynced(rc, "", nil)
+	})
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
-	values := replicator.Merge(rc.Spec.TemplateValues.Values, secrets)
+	for namespace, err := range failures {
+		log.Warnf("failed to synchronize %s%q to namespace %q, retrying: %v", rc.Kind, rc.Name, namespace, err)
+		r.retryNamespace(rc.Name, namespace)
+	}
 
-	ownerRef := []metav1.OwnerReference{
-		{
-			APIVersion: rc.APIVersion,
-			Kind:       rc.Kind,
-			Name:       rc.Name,
-			UID:        rc.UID,
-		},
+	log.Infof("finished reconcile %s%q to %d namespaces, %d failed\n", rc.Kind, rc.Name, len(namespaces.Items), len(failures))
+
+	return ctrl.Result{}, nil
+}
+
+// syncNamespaces synchronizes the namespaces and prunes the resources no longer rendered in them, a number of namespaces at a time.
+// It returns the inventory and conflicts of the namespaces, and the error of each namespace that failed.
+// A failing namespace does not stop the others from being synchronized, it is recorded and retried on its own.
+func (r *ReplicationConfigReconciler) syncNamespaces(ctx context.Context, rc *naisiov1.ReplicationConfig, namespaces []v1.Namespace, scope replicator.Scope) (syncResult, map[string]error) {
+	results := make([]syncResult, len(namespaces))
+	errs := make([]error, len(namespaces))
+
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, r.namespaceWorkers(rc))
+	for i, ns := range namespaces {
+		workers <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-workers }()
+			results[i], errs[i] = r.syncAndPruneNamespace(ctx, rc, ns, scope)
+		})
 	}
+	wg.Wait()
+
+	// the results are combined in the order of the namespaces, to keep the status stable
+	var all syncResult
+	failures := make(map[string]error)
+	for i, ns := range namespaces {
+		if errs[i] != nil {
+			failures[ns.Name] = errs[i]
+		}
+		all.resources = append(all.resources, results[i].resources...)
+		all.conflicts = append(all.conflicts, results[i].conflicts...)
+		all.ignored = append(all.ignored, results[i].ignored...)
+	}
+	return all, failures
+}
 
-	for _, ns := range namespaces.Items {
-		nsv := replicator.ExtractValues(ns, rc.Spec.TemplateValues.Namespace)
+// syncAndPruneNamespace synchronizes a namespace and prunes the resources no longer rendered in it.
+// When it fails, the result keeps the previous inventory of the namespace, so nothing is pruned from it.
+func (r *ReplicationConfigReconciler) syncAndPruneNamespace(ctx context.Context, rc *naisiov1.ReplicationConfig, ns v1.Namespace, scope replicator.Scope) (syncResult, error) {
+	previous := replicator.InNamespace(rc.Status.Resources, ns.Name)
+	result, err := r.syncNamespace(ctx, rc, ns, scope)
+	if err != nil {
+		return syncResult{
+			resources: previous,
+			conflicts: conflictsInNamespace(rc.Status.Conflicts, ns.Name),
+			ignored:   replicator.InNamespace(rc.Status.Ignored, ns.Name),
+		}, err
+	}
 
-		renderResources, err := replicator.RenderResources(&replicator.TemplateValues{Values: replicator.Merge(values, nsv)}, rc.Spec.Resources)
-		if err != nil {
-			r.Recorder.Eventf(rc, "Warning", "RenderResources", "Unable to render resources for namespace %q: %v", ns.Name, err)
-			return ctrl.Result{}, err
+	var pruneErr error
+	for _, ref := range replicator.Stale(previous, result.resources) {
+		if err := r.prune(ctx, rc, ref); err != nil {
+			r.Recorder.Eventf(rc, "Warning", "Prune", "Unable to prune resource %v/%v for namespace %q: %v", ref.Kind, ref.Name, ref.Namespace, err)
+			pruneErr = errors.Join(pruneErr, fmt.Errorf("pruning resource %v/%v: %w", ref.Kind, ref.Name, err))
+			result.resources = append(result.resources, ref)
 		}
+	}
+	return result, pruneErr
+}
 
-		log.Debugf("rendered %d resources for namespace %q", len(renderResources), ns.Name)
+// namespaceWorkers returns the number of namespaces synchronized at the same time, which may be overridden by the ReplicationConfig.
+func (r *ReplicationConfigReconciler) namespaceWorkers(rc *naisiov1.ReplicationConfig) int {
+	if rc.Spec.Concurrency > 0 {
+		return rc.Spec.Concurrency
+	}
+	return max(r.NamespaceWorkers, 1)
+}
 
-		for _, resource := range renderResources {
-			log.Debugf("reconciling resource %s%q", resource.GetKind(), resource.GetName())
-			if os.Getenv("DEBUG") == "true" {
-				spew.Dump(resource)
-			}
+// syncResult is the outcome of synchronizing a single namespace.
+type syncResult struct {
+	// resources is the inventory of the namespace
+	resources []naisiov1.ResourceReference
+	// changed lists the resources that were created or updated
+	changed []naisiov1.ResourceReference
+	// conflicts lists the resources that are owned by another ReplicationConfig
+	conflicts []naisiov1.ResourceConflict
+	// ignored lists the resources that were left untouched because of the ignore annotation
+	ignored []naisiov1.ResourceReference
+}
 
-			resource.SetNamespace(ns.Name)
-			resource.SetOwnerReferences(ownerRef)
-			err = r.createUpdateResource(ctx, resource)
-			if err != nil {
-				if apierrors.HasStatusCause(err, v1.NamespaceTerminatingCause) {
-					log.Infof("namespace %q is terminating, skipping resource %v/%v", ns.Name, resource.GetKind(), resource.GetName())
-					continue
-				}
-				r.Recorder.Eventf(rc, "Warning", "createUpdateResource", "Unable to create/update resource %v/%v for namespace %q: %v", resource.GetKind(), resource.GetName(), ns.Name, err)
-				return ctrl.Result{}, err
-			}
-		}
+// renderNamespace renders the resources of the ReplicationConfig for a single namespace, ready to be written to it.
+func (r *ReplicationConfigReconciler) renderNamespace(rc *naisiov1.ReplicationConfig, ns v1.Namespace, scope replicator.Scope) ([]*unstructured.Unstructured, error) {
+	resources, err := replicator.ForNamespace(rc.Spec.Resources, ns)
+	if err != nil {
+		return nil, err
 	}
 
-	// Get the latest version of the ReplicationConfig before updating status.
-	rc = &naisiov1.ReplicationConfig{}
-	err = r.Get(ctx, req.NamespacedName, rc)
+	values := scope.With(replicator.ExtractValues(ns, rc.Spec.TemplateValues.Namespace)).Resolve()
+	renderResources, err := replicator.RenderResources(replicator.NewTemplateValues(rc, ns, values), resources)
 	if err != nil {
-		return ctrl.Result{}, err
+		r.Recorder.Eventf(rc, "Warning", "RenderResources", "Unable to render resources for namespace %q: %v", ns.Name, err)
+		return nil, err
 	}
 
-	rc.Status.SynchronizationTimestamp = metav1.Now()
-	rc.Status.SynchronizationHash = hash
-	if err := r.Status().Update(ctx, rc); err != nil {
-		r.Recorder.Eventf(rc, "Warning", "UpdateStatus", "Unable to update status for %q: %v", rc.Name, err)
-		return ctrl.Result{}, err
+	log.Debugf("rendered %d resources for namespace %q", len(renderResources), ns.Name)
+
+	for _, resource := range renderResources {
+		resource.SetNamespace(ns.Name)
+		resource.SetOwnerReferences(ownerReferences(rc))
+		if err := content.SetLastApplied(resource); err != nil {
+			return nil, err
+		}
+	}
+	return renderResources, nil
+}
+
+// syncNamespace renders and applies the resources of the ReplicationConfig to a single namespace.
+func (r *ReplicationConfigReconciler) syncNamespace(ctx context.Context, rc *naisiov1.ReplicationConfig, ns v1.Namespace, scope replicator.Scope) (syncResult, error) {
+	renderResources, err := r.renderNamespace(rc, ns, scope)
+	if err != nil {
+		return syncResult{}, err
 	}
 
-	log.Infof("finished reconcile %s%q to %d namespaces\n", rc.Kind, rc.Name, len(namespaces.Items))
+	var result syncResult
+	for _, resource := range renderResources {
+		log.Debugf("reconciling resource %s%q", resource.GetKind(), resource.GetName())
+		if os.Getenv("DEBUG") == "true" {
+			spew.Dump(resource)
+		}
 
-	return ctrl.Result{}, nil
+		op, err := r.writeResource(ctx, rc, resource, false)
+		var conflict *ownerConflictError
+		if errors.As(err, &conflict) {
+			log.Warnf("%v, leaving it untouched", err)
+			result.conflicts = append(result.conflicts, naisiov1.ResourceConflict{ResourceReference: replicator.Reference(resource), Owner: conflict.owner})
+			continue
+		}
+		if err != nil {
+			if apierrors.HasStatusCause(err, v1.NamespaceTerminatingCause) {
+				log.Infof("namespace %q is terminating, skipping resource %v/%v", ns.Name, resource.GetKind(), resource.GetName())
+				continue
+			}
+			r.Recorder.Eventf(rc, "Warning", "createUpdateResource", "Unable to create/update resource %v/%v for namespace %q: %v", resource.GetKind(), resource.GetName(), ns.Name, err)
+			return syncResult{}, err
+		}
+		if op == operationResultSkipped {
+			continue
+		}
+
+		if err := r.watchResource(resource.GroupVersionKind()); err != nil {
+			log.Warnf("unable to watch %v for drift: %v", resource.GroupVersionKind(), err)
+		}
+
+		ref := replicator.Reference(resource)
+		result.resources = append(result.resources, ref)
+		if op == operationResultIgnored {
+			result.ignored = append(result.ignored, ref)
+			continue
+		}
+		if op != controllerutil.OperationResultNone {
+			result.changed = append(result.changed, ref)
+		}
+	}
+	return result, nil
 }
 
 func (r *ReplicationConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	r.cache = mgr.GetCache()
+	r.watched = make(map[schema.GroupVersionKind]bool)
+	r.retries = make(chan event.TypedGenericEvent[reconcile.Request])
+
+	c, err := ctrl.NewControllerManagedBy(mgr).
 		For(&naisiov1.ReplicationConfig{}).
-		Complete(r)
+		Watches(
+			&v1.Namespace{},
+			handler.EnqueueRequestsFromMapFunc(r.namespaceRequests),
+			builder.WithPredicates(createdAfter(time.Now()), predicate.Or(predicate.LabelChangedPredicate{}, predicate.AnnotationChangedPredicate{})),
+		).
+		Watches(
+			&v1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(r.secretRequests),
+			builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+				return obj.GetNamespace() == os.Getenv("POD_NAMESPACE")
+			})),
+		).
+		Watches(
+			&v1.ConfigMap{},
+			handler.EnqueueRequestsFromMapFunc(r.configMapRequests),
+			builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+				return obj.GetNamespace() == os.Getenv("POD_NAMESPACE")
+			})),
+		).
+		WatchesRawSource(source.TypedChannel(r.retries, retryHandler)).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Build(r)
+	if err != nil {
+		return err
+	}
+	r.controller = c
+	return nil
 }
 
-func (r *ReplicationConfigReconciler) listNamespaces(ctx context.Context, ls *metav1.LabelSelector) (v1.NamespaceList, error) {
-	selector, err := metav1.LabelSelectorAsSelector(ls)
+// lockConfig serializes the reconciles of a ReplicationConfig and returns the unlock function.
+// Requests for a single namespace are different workqueue keys than the full synchronization, and would otherwise run
+// at the same time with --max-concurrent-reconciles, overwriting each other's inventory in the status.
+func (r *ReplicationConfigReconciler) lockConfig(name string) func() {
+	mu, _ := r.configLocks.LoadOrStore(name, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+// listNamespaces returns the namespaces targeted by the ReplicationConfig: the ones matching the namespaceSelector,
+// except those opting out with the exclude annotation. The names of the excluded namespaces are returned along with them.
+func (r *ReplicationConfigReconciler) listNamespaces(ctx context.Context, rc *naisiov1.ReplicationConfig) (v1.NamespaceList, []string, error) {
+	selector, err := metav1.LabelSelectorAsSelector(&rc.Spec.NamespaceSelector)
 	if err != nil {
-		return v1.NamespaceList{}, err
+		return v1.NamespaceList{}, nil, err
 	}
 
 	var namespaces v1.NamespaceList
 	err = r.List(ctx, &namespaces, &client.ListOptions{LabelSelector: selector})
 	if err != nil {
-		return v1.NamespaceList{}, err
+		return v1.NamespaceList{}, nil, err
 	}
-	return namespaces, nil
+
+	var excluded []string
+	targeted := namespaces.Items[:0]
+	for _, ns := range namespaces.Items {
+		if excludes(ns.Annotations, rc.Name) {
+			excluded = append(excluded, ns.Name)
+			continue
+		}
+		targeted = append(targeted, ns)
+	}
+	namespaces.Items = targeted
+	return namespaces, excluded, nil
 }
 
-func (r *ReplicationConfigReconciler) createUpdateResource(ctx context.Context, resource *unstructured.Unstructured) error {
+// writeResource creates or updates the resource using the apply strategy of the ReplicationConfig.
+// An existing resource that is not owned by the ReplicationConfig is handled according to its conflictPolicy.
+// With dryRun the requests are only validated by the server, and the result tells what would have happened.
+func (r *ReplicationConfigReconciler) writeResource(ctx context.Context, rc *naisiov1.ReplicationConfig, resource *unstructured.Unstructured, dryRun bool) (controllerutil.OperationResult, error) {
 	existing := &unstructured.Unstructured{}
 	existing.SetGroupVersionKind(resource.GroupVersionKind())
 	err := r.Get(ctx, client.ObjectKeyFromObject(resource), existing)
 	if client.IgnoreNotFound(err) != nil {
-		return err
+		return controllerutil.OperationResultNone, err
 	}
-
 	if apierrors.IsNotFound(err) {
-		err := r.Create(ctx, resource)
-		if client.IgnoreAlreadyExists(err) != nil {
-			return err
+		existing = nil
+	} else if existing.GetAnnotations()[ignoreAnnotation] == "true" {
+		if !dryRun {
+			log.Infof("ignoring resource %s%q in namespace %q with annotation %s", existing.GetKind(), existing.GetName(), existing.GetNamespace(), ignoreAnnotation)
+			r.Recorder.Eventf(rc, "Normal", "Ignored", "Left resource %v/%v in namespace %q untouched, it has the %s annotation", existing.GetKind(), existing.GetName(), existing.GetNamespace(), ignoreAnnotation)
+		}
+		return operationResultIgnored, nil
+	} else if !isOwnedBy(existing, rc) {
+		if write, err := r.resolveConflict(rc, existing, dryRun); !write {
+			return operationResultSkipped, err
 		}
+	}
+
+	if rc.Spec.ApplyStrategy == naisiov1.ApplyStrategyServerSideApply {
+		return r.applyResource(ctx, resource, existing, rc.Spec.ForceConflicts, dryRun)
+	}
+	if existing == nil {
+		return r.createResource(ctx, resource, dryRun)
+	}
+	return r.updateResource(ctx, resource, existing, dryRun)
+}
+
+func (r *ReplicationConfigReconciler) createResource(ctx context.Context, resource *unstructured.Unstructured, dryRun bool) (controllerutil.OperationResult, error) {
+	var opts []client.CreateOption
+	if dryRun {
+		opts = append(opts, client.DryRunAll)
+	}
+	err := r.Create(ctx, resource, opts...)
+	if client.IgnoreAlreadyExists(err) != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	if !dryRun {
 		log.Infof("created resource %v/%v for namespace %q", resource.GetKind(), resource.GetName(), resource.GetNamespace())
-		return nil
 	}
+	return controllerutil.OperationResultCreated, nil
+}
 
-	err = r.updateResource(ctx, resource, existing)
+func (r *ReplicationConfigReconciler) updateResource(ctx context.Context, resource, existing *unstructured.Unstructured, dryRun bool) (controllerutil.OperationResult, error) {
+	// owner references are compared as well, so an adopted resource gets them even if its content is unchanged
+	if contentEquals(resource, existing) && equality.Semantic.DeepEqual(resource.GetOwnerReferences(), existing.GetOwnerReferences()) {
+		log.Debugf("unchanged resource %s%q for namespace %q", resource.GetKind(), resource.GetName(), resource.GetNamespace())
+		return controllerutil.OperationResultNone, nil
+	}
+	var opts []client.UpdateOption
+	if dryRun {
+		opts = append(opts, client.DryRunAll)
+	}
+
+	resource.SetResourceVersion(existing.GetResourceVersion())
+	err := r.Update(ctx, resource, opts...)
 	if err != nil {
-		return err
+		return controllerutil.OperationResultNone, fmt.Errorf("updating resource: %w", err)
 	}
-	return nil
+	if dryRun {
+		return controllerutil.OperationResultUpdated, nil
+	}
+	log.Infof("updated resource %s%q to namespace %q", resource.GetKind(), resource.GetName(), resource.GetNamespace())
+	return controllerutil.OperationResultUpdated, nil
 }
 
-func (r *ReplicationConfigReconciler) updateResource(ctx context.Context, resource, existing *unstructured.Unstructured) error {
+// applyResource writes the resource with server-side apply, so that fields set by others are left untouched.
+// A nil existing resource is created.
+func (r *ReplicationConfigReconciler) applyResource(ctx context.Context, resource, existing *unstructured.Unstructured, force, dryRun bool) (controllerutil.OperationResult, error) {
+	// a dry run does not change the resource version, so compare the rendered fields instead
+	changed := true
+	if dryRun && existing != nil {
+		changed = !contentEquals(resource, existing)
+	}
+
+	opts := []client.ApplyOption{client.FieldOwner(fieldManager)}
+	if dryRun {
+		opts = append(opts, client.DryRunAll)
+	}
+	if force {
+		opts = append(opts, client.ForceOwnership)
+	}
+
+	if err := r.Apply(ctx, client.ApplyConfigurationFromUnstructured(resource), opts...); err != nil {
+		return controllerutil.OperationResultNone, fmt.Errorf("applying resource: %w", err)
+	}
+
+	switch {
+	case dryRun && existing == nil:
+		return controllerutil.OperationResultCreated, nil
+	case dryRun && changed:
+		return controllerutil.OperationResultUpdated, nil
+	case dryRun:
+		return controllerutil.OperationResultNone, nil
+	case existing == nil:
+		log.Infof("created resource %v/%v for namespace %q", resource.GetKind(), resource.GetName(), resource.GetNamespace())
+		return controllerutil.OperationResultCreated, nil
+	case existing.GetResourceVersion() != resource.GetResourceVersion():
+		log.Infof("updated resource %s%q to namespace %q", resource.GetKind(), resource.GetName(), resource.GetNamespace())
+		return controllerutil.OperationResultUpdated, nil
+	default:
+		log.Debugf("unchanged resource %s%q for namespace %q", resource.GetKind(), resource.GetName(), resource.GetNamespace())
+		return controllerutil.OperationResultNone, nil
+	}
+}
+
+// contentEquals reports whether the existing resource has the content of the rendered resource.
+// Resources without comparable content are considered equal.
+func contentEquals(resource, existing *unstructured.Unstructured) bool {
 	resourceContent, err := content.Get(resource)
 	if err != nil {
 		log.Warnf("unable to get resource content type: %v", err)
-		return nil
+		return true
 	}
 
 	existingContent, err := content.Get(existing)
 	if err != nil {
 		log.Warnf("unable to get existing content type: %v", err)
-		return nil
+		return true
 	}
 
-	if resourceContent.Equals(existingContent) {
-		log.Debugf("unchanged resource %s%q for namespace %q", resource.GetKind(), resource.GetName(), resource.GetNamespace())
-		return nil
-	}
+	return resourceContent.Equals(existingContent)
+}
 
-	resource.SetResourceVersion(existing.GetResourceVersion())
-	err = r.Update(ctx, resource)
-	if err != nil {
-		return fmt.Errorf("updating resource: %w", err)
+// ownerReferences refers to the ReplicationConfig from its replicated resources.
+// The type is not taken from rc, as its TypeMeta is cleared when it is decoded after an update.
+func ownerReferences(rc *naisiov1.ReplicationConfig) []metav1.OwnerReference {
+	return []metav1.OwnerReference{
+		{
+			APIVersion: naisiov1.GroupVersion.String(),
+			Kind:       "ReplicationConfig",
+			Name:       rc.Name,
+			UID:        rc.UID,
+		},
 	}
-	log.Infof("updated resource %s%q to namespace %q", resource.GetKind(), resource.GetName(), resource.GetNamespace())
-	return nil
 }
 
 func (r *ReplicationConfigReconciler) needsSync(timestamp time.Time) bool {
@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	naisiov1 "nais/replicator/api/v1"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func newReconciler(t *testing.T, funcs interceptor.Funcs, objects ...client.Object) *ReplicationConfigReconciler {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, clientgoscheme.AddToScheme(scheme))
+	assert.NoError(t, naisiov1.AddToScheme(scheme))
+	return &ReplicationConfigReconciler{
+		Client: fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(objects...).
+			WithStatusSubresource(&naisiov1.ReplicationConfig{}).
+			WithInterceptorFuncs(funcs).
+			Build(),
+		Scheme:           scheme,
+		Recorder:         record.NewFakeRecorder(1000),
+		SyncInterval:     time.Hour,
+		NamespaceWorkers: 2,
+		retries:          make(chan event.TypedGenericEvent[reconcile.Request], 100),
+	}
+}
+
+func toUnstructured(t *testing.T, obj runtime.Object) *unstructured.Unstructured {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	assert.NoError(t, err)
+	return &unstructured.Unstructured{Object: content}
+}
+
+func teamNamespace(name, team string) *v1.Namespace {
+	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"replicate": "true"}}}
+	if team != "" {
+		ns.Labels["team"] = team
+	}
+	return ns
+}
+
+func getReplicationConfig(t *testing.T, r *ReplicationConfigReconciler, name string) *naisiov1.ReplicationConfig {
+	rc := &naisiov1.ReplicationConfig{}
+	assert.NoError(t, r.Get(context.Background(), client.ObjectKey{Name: name}, rc))
+	return rc
+}
+
+// replicated reports whether the rendered config map has been replicated to the namespace.
+func replicated(t *testing.T, r *ReplicationConfigReconciler, namespace string) bool {
+	err := r.Get(context.Background(), client.ObjectKey{Name: "team", Namespace: namespace}, &v1.ConfigMap{})
+	if apierrors.IsNotFound(err) {
+		return false
+	}
+	assert.NoError(t, err)
+	return true
+}
+
+func TestRollout(t *testing.T) {
+	// namespace b has no team label, so rendering fails there until it is labeled
+	rc := &naisiov1.ReplicationConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "rollout", UID: types.UID("rollout-uid")},
+		Spec: naisiov1.ReplicationConfigSpec{
+			NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"replicate": "true"}},
+			TemplateValues:    naisiov1.TemplateValues{Namespace: naisiov1.Namespace{Labels: []string{"team"}}},
+			Resources: []naisiov1.Resource{{
+				Template: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: team\ndata:\n  team: '[[ required \"team label\" .Values.team ]]'\n",
+			}},
+			Rollout: &naisiov1.Rollout{BatchSize: new(intstr.FromInt32(2))},
+		},
+	}
+	r := newReconciler(t, interceptor.Funcs{}, rc,
+		teamNamespace("a", "a"), teamNamespace("b", ""), teamNamespace("c", "c"), teamNamespace("d", "d"))
+	ctx := context.Background()
+	full := ctrl.Request{NamespacedName: types.NamespacedName{Name: rc.Name}}
+
+	// the first wave is rolled out to a and b, and b fails
+	_, err := r.Reconcile(ctx, full)
+	assert.NoError(t, err)
+	state := getReplicationConfig(t, r, rc.Name).Status.Rollout
+	assert.Equal(t, naisiov1.RolloutProgressing, state.Phase)
+	assert.Equal(t, []string{"a", "b"}, state.Namespaces)
+	assert.True(t, replicated(t, r, "a"))
+	assert.False(t, replicated(t, r, "c"))
+
+	// the rollout is halted, as more namespaces are failing than allowed by maxFailures
+	_, err = r.Reconcile(ctx, full)
+	assert.NoError(t, err)
+	status := getReplicationConfig(t, r, rc.Name).Status
+	assert.Equal(t, naisiov1.RolloutHalted, status.Rollout.Phase)
+	assert.Equal(t, []naisiov1.NamespaceFailure{{Namespace: "b", Error: status.Failures[0].Error}}, status.Failures)
+	assert.False(t, replicated(t, r, "c"))
+
+	// a namespace that has not had its wave is held back, also when it is reconciled on its own
+	_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: rc.Name, Namespace: "d"}})
+	assert.NoError(t, err)
+	assert.False(t, replicated(t, r, "d"))
+
+	// the failing namespace recovers when it is retried
+	b := &v1.Namespace{}
+	assert.NoError(t, r.Get(ctx, client.ObjectKey{Name: "b"}, b))
+	b.Labels["team"] = "b"
+	assert.NoError(t, r.Update(ctx, b))
+	_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: rc.Name, Namespace: "b"}})
+	assert.NoError(t, err)
+	assert.True(t, replicated(t, r, "b"))
+	assert.Empty(t, getReplicationConfig(t, r, rc.Name).Status.Failures)
+
+	// the rollout is resumed with the next wave
+	_, err = r.Reconcile(ctx, full)
+	assert.NoError(t, err)
+	state = getReplicationConfig(t, r, rc.Name).Status.Rollout
+	assert.Equal(t, naisiov1.RolloutProgressing, state.Phase)
+	assert.Equal(t, 2, state.Wave)
+	assert.Equal(t, []string{"a", "b", "c", "d"}, state.Namespaces)
+	assert.True(t, replicated(t, r, "d"))
+
+	// the rollout is complete, and the full synchronization takes over
+	_, err = r.Reconcile(ctx, full)
+	assert.NoError(t, err)
+	status = getReplicationConfig(t, r, rc.Name).Status
+	assert.Equal(t, naisiov1.RolloutComplete, status.Rollout.Phase)
+	assert.Equal(t, status.Rollout.Hash, status.SynchronizationHash)
+	assert.Len(t, status.Resources, 4)
+}
+
+func TestRolloutNamespaceStopsMatching(t *testing.T) {
+	rc := &naisiov1.ReplicationConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "rollout", UID: types.UID("rollout-uid")},
+		Spec: naisiov1.ReplicationConfigSpec{
+			NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"replicate": "true"}},
+			Resources:         []naisiov1.Resource{{Template: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: team\n"}},
+			Rollout:           &naisiov1.Rollout{BatchSize: new(intstr.FromInt32(1))},
+		},
+	}
+	r := newReconciler(t, interceptor.Funcs{}, rc, teamNamespace("a", "a"), teamNamespace("b", "b"))
+	ctx := context.Background()
+	full := ctrl.Request{NamespacedName: types.NamespacedName{Name: rc.Name}}
+
+	_, err := r.Reconcile(ctx, full)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, getReplicationConfig(t, r, rc.Name).Status.Rollout.Namespaces)
+
+	// a rolled out namespace that no longer matches is left out of the rollout
+	a := &v1.Namespace{}
+	assert.NoError(t, r.Get(ctx, client.ObjectKey{Name: "a"}, a))
+	delete(a.Labels, "replicate")
+	assert.NoError(t, r.Update(ctx, a))
+
+	_, err = r.Reconcile(ctx, full)
+	assert.NoError(t, err)
+	state := getReplicationConfig(t, r, rc.Name).Status.Rollout
+	assert.Equal(t, []string{"b"}, state.Namespaces)
+	assert.Equal(t, 1, state.TotalNamespaces)
+}
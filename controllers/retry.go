@@ -0,0 +1,27 @@
+package controllers
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// retryHandler adds the requests of failed namespaces to the queue with backoff.
+// Subsequent failures are backed off by the controller, as the namespace request returns the error.
+var retryHandler = handler.TypedFuncs[reconcile.Request, reconcile.Request]{
+	GenericFunc: func(_ context.Context, e event.TypedGenericEvent[reconcile.Request], q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+		q.AddRateLimited(e.Object)
+	},
+}
+
+// retryNamespace requeues the synchronization of a single namespace that failed during a full synchronization.
+func (r *ReplicationConfigReconciler) retryNamespace(name, namespace string) {
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: namespace}}
+	go func() {
+		r.retries <- event.TypedGenericEvent[reconcile.Request]{Object: req}
+	}()
+}
@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	naisiov1 "nais/replicator/api/v1"
+	"nais/replicator/internal/replicator"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// rollout synchronizes the next wave of namespaces with the spec with the given hash, once the pause after the previous wave has passed.
+// The rollout is halted while more of the rolled out namespaces are failing than allowed by maxFailures, and resumed when they recover.
+// It returns done when the change has been rolled out to every namespace, so the full synchronization can take over.
+func (r *ReplicationConfigReconciler) rollout(ctx context.Context, rc *naisiov1.ReplicationConfig, hash string, namespaces []v1.Namespace, scope replicator.Scope) (ctrl.Result, bool, error) {
+	spec := rc.Spec.Rollout
+	state := rc.Status.Rollout.DeepCopy()
+	if state == nil || state.Hash != hash {
+		log.Infof("starting rollout of %s%q with hash %q", rc.Kind, rc.Name, hash)
+		state = &naisiov1.RolloutStatus{Hash: hash, Phase: naisiov1.RolloutProgressing}
+	}
+
+	if state.Phase == naisiov1.RolloutComplete {
+		return ctrl.Result{}, true, nil
+	}
+
+	if state.NextWaveTime != nil {
+		if wait := time.Until(state.NextWaveTime.Time); wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, false, nil
+		}
+	}
+
+	canary := labels.Nothing()
+	if spec.Canary != nil {
+		selector, err := metav1.LabelSelectorAsSelector(spec.Canary)
+		if err != nil {
+			return ctrl.Result{}, false, r.syncFailed(ctx, rc.Name, "InvalidRollout", fmt.Errorf("parsing canary selector: %w", err))
+		}
+		canary = selector
+	}
+
+	order, canaries := replicator.RolloutOrder(namespaces, canary)
+	// namespaces that no longer match the namespaceSelector are not part of the rollout
+	state.Namespaces = slices.DeleteFunc(state.Namespaces, func(name string) bool {
+		return !slices.Contains(order, name)
+	})
+	state.TotalNamespaces = len(order)
+
+	batchSize, err := intstr.GetScaledValueFromIntOrPercent(intstr.ValueOrDefault(spec.BatchSize, intstr.FromString("25%")), len(order), true)
+	if err != nil {
+		return ctrl.Result{}, false, r.syncFailed(ctx, rc.Name, "InvalidRollout", fmt.Errorf("parsing batchSize: %w", err))
+	}
+	maxFailures, err := intstr.GetScaledValueFromIntOrPercent(intstr.ValueOrDefault(spec.MaxFailures, intstr.FromInt32(0)), len(state.Namespaces), false)
+	if err != nil {
+		return ctrl.Result{}, false, r.syncFailed(ctx, rc.Name, "InvalidRollout", fmt.Errorf("parsing maxFailures: %w", err))
+	}
+
+	// the failing namespaces are checked after the pause, so retries get a chance to recover them
+	failing := 0
+	for _, f := range rc.Status.Failures {
+		if slices.Contains(state.Namespaces, f.Namespace) {
+			failing++
+		}
+	}
+	if failing > maxFailures {
+		message := fmt.Sprintf("%d of %d rolled out namespaces are failing, %d allowed by maxFailures", failing, len(state.Namespaces), maxFailures)
+		if state.Phase != naisiov1.RolloutHalted {
+			log.Warnf("halting rollout of %s%q: %s", rc.Kind, rc.Name, message)
+			r.Recorder.Eventf(rc, "Warning", "RolloutHalted", "Halted rollout after wave %d: %s", state.Wave, message)
+		}
+		state.Phase = naisiov1.RolloutHalted
+		state.Message = message
+		return ctrl.Result{}, false, r.updateStatus(ctx, rc.Name, func(rc *naisiov1.ReplicationConfig) {
+			rc.Status.Rollout = state
+			setSynced(rc, "RolloutHalted", errors.New(message))
+		})
+	}
+	state.Phase = naisiov1.RolloutProgressing
+	state.Message = ""
+
+	names := replicator.NextWave(order, canaries, state.Namespaces, max(batchSize, 1))
+	if len(names) == 0 {
+		log.Infof("finished rollout of %s%q to %d namespaces in %d waves", rc.Kind, rc.Name, len(order), state.Wave)
+		state.Phase = naisiov1.RolloutComplete
+		state.NextWaveTime = nil
+		return ctrl.Result{}, true, r.updateStatus(ctx, rc.Name, func(rc *naisiov1.ReplicationConfig) {
+			rc.Status.Rollout = state
+		})
+	}
+
+	var wave []v1.Namespace
+	for _, ns := range namespaces {
+		if slices.Contains(names, ns.Name) {
+			wave = append(wave, ns)
+		}
+	}
+
+	result, failures := r.syncNamespaces(ctx, rc, wave, scope)
+
+	state.Wave++
+	state.Namespaces = append(state.Namespaces, names...)
+	state.NextWaveTime = &metav1.Time{Time: time.Now().Add(spec.Pause.Duration)}
+	r.Recorder.Eventf(rc, "Normal", "Rollout", "Rolled out wave %d to namespaces %s, %d failed", state.Wave, strings.Join(names, ", "), len(failures))
+
+	err = r.updateStatus(ctx, rc.Name, func(rc *naisiov1.ReplicationConfig) {
+		for _, namespace := range names {
+			rc.Status.Resources = replicator.ReplaceNamespace(rc.Status.Resources, namespace, replicator.InNamespace(result.resources, namespace))
+			setNamespaceConflicts(&rc.Status, namespace, conflictsInNamespace(result.conflicts, namespace))
+			rc.Status.Ignored = replicator.ReplaceNamespace(rc.Status.Ignored, namespace, replicator.InNamespace(result.ignored, namespace))
+			setNamespaceFailure(&rc.Status, namespace, failures[namespace])
+		}
+		rc.Status.TargetedNamespaces = len(order)
+		rc.Status.DryRun = nil
+		rc.Status.Rollout = state
+		setSynced(rc, "RolloutProgressing", fmt.Errorf("rolled out to %d of %d namespaces", len(state.Namespaces), len(order)))
+	})
+	if err != nil {
+		return ctrl.Result{}, false, err
+	}
+
+	for namespace, err := range failures {
+		log.Warnf("failed to roll out %s%q to namespace %q, retrying: %v", rc.Kind, rc.Name, namespace, err)
+		r.retryNamespace(rc.Name, namespace)
+	}
+
+	log.Infof("rolled out wave %d of %s%q to %d namespaces, %d failed", state.Wave, rc.Kind, rc.Name, len(names), len(failures))
+
+	return ctrl.Result{RequeueAfter: spec.Pause.Duration}, false, nil
+}
+
+// rolledOut tells whether a targeted synchronization of the namespace may apply the spec with the given hash.
+// During a rollout, a change is held back from a namespace until its wave.
+func rolledOut(rc *naisiov1.ReplicationConfig, hash, namespace string) bool {
+	if rc.Spec.Rollout == nil || rc.Status.SynchronizationHash == hash {
+		return true
+	}
+	state := rc.Status.Rollout
+	return state != nil && state.Hash == hash && (state.Phase == naisiov1.RolloutComplete || slices.Contains(state.Namespaces, namespace))
+}
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	naisiov1 "nais/replicator/api/v1"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// secretRequests maps a secret event to a request per ReplicationConfig that loads values from the secret.
+func (r *ReplicationConfigReconciler) secretRequests(ctx context.Context, obj client.Object) []reconcile.Request {
+	var rcs naisiov1.ReplicationConfigList
+	if err := r.List(ctx, &rcs); err != nil {
+		log.Errorf("listing ReplicationConfigs for secret %q: %v", obj.GetName(), err)
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, rc := range rcs.Items {
+		for _, s := range rc.Spec.TemplateValues.Secrets {
+			if s.Name == obj.GetName() {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: rc.Name}})
+				break
+			}
+		}
+	}
+	return requests
+}
+
+// configMapRequests maps a config map event to a request per ReplicationConfig that loads values from the config map.
+func (r *ReplicationConfigReconciler) configMapRequests(ctx context.Context, obj client.Object) []reconcile.Request {
+	var rcs naisiov1.ReplicationConfigList
+	if err := r.List(ctx, &rcs); err != nil {
+		log.Errorf("listing ReplicationConfigs for config map %q: %v", obj.GetName(), err)
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, rc := range rcs.Items {
+		for _, cm := range rc.Spec.TemplateValues.ConfigMaps {
+			if cm.Name == obj.GetName() {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: rc.Name}})
+				break
+			}
+		}
+	}
+	return requests
+}
+
+// recordCollisions records the values set by more than one source in the status, with an event when they change.
+func (r *ReplicationConfigReconciler) recordCollisions(ctx context.Context, rc *naisiov1.ReplicationConfig, collisions []naisiov1.ValueCollision) error {
+	if equality.Semantic.DeepEqual(rc.Status.Collisions, collisions) {
+		return nil
+	}
+
+	for _, c := range collisions {
+		log.Warnf("value %q of %s%q is set by %s, the last one is used", c.Key, rc.Kind, rc.Name, strings.Join(c.Sources, ", "))
+		r.Recorder.Eventf(rc, "Warning", "ValueCollision", "Value %q is set by %s, the last one is used", c.Key, strings.Join(c.Sources, ", "))
+	}
+	return r.updateStatus(ctx, rc.Name, func(rc *naisiov1.ReplicationConfig) {
+		rc.Status.Collisions = collisions
+	})
+}
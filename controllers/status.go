@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	naisiov1 "nais/replicator/api/v1"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// updateStatus applies update to the latest version of the ReplicationConfig and updates the status if it changed.
+func (r *ReplicationConfigReconciler) updateStatus(ctx context.Context, name string, update func(rc *naisiov1.ReplicationConfig)) error {
+	rc := &naisiov1.ReplicationConfig{}
+	if err := r.Get(ctx, client.ObjectKey{Name: name}, rc); err != nil {
+		return err
+	}
+
+	status := rc.Status.DeepCopy()
+	update(rc)
+	if equality.Semantic.DeepEqual(status, &rc.Status) {
+		return nil
+	}
+
+	if err := r.Status().Update(ctx, rc); err != nil {
+		r.Recorder.Eventf(rc, "Warning", "UpdateStatus", "Unable to update status for %q: %v", rc.Name, err)
+		return err
+	}
+	return nil
+}
+
+// syncFailed records that a full synchronization failed before reaching the namespaces, and returns err.
+func (r *ReplicationConfigReconciler) syncFailed(ctx context.Context, name, reason string, err error) error {
+	statusErr := r.updateStatus(ctx, name, func(rc *naisiov1.ReplicationConfig) {
+		setSynced(rc, reason, err)
+	})
+	if statusErr != nil {
+		log.Errorf("unable to record failed synchronization of %q: %v", name, statusErr)
+	}
+	return err
+}
+
+// setSynced records the outcome of a full synchronization, with the reason it failed if err is set.
+func setSynced(rc *naisiov1.ReplicationConfig, reason string, err error) {
+	condition := metav1.Condition{
+		Type:               naisiov1.ConditionSynced,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Synchronized",
+		Message:            "Synchronized to the targeted namespaces",
+		ObservedGeneration: rc.Generation,
+	}
+	if err != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = reason
+		condition.Message = err.Error()
+	}
+	meta.SetStatusCondition(&rc.Status.Conditions, condition)
+	meta.RemoveStatusCondition(&rc.Status.Conditions, naisiov1.ConditionSuspended)
+	rc.Status.ObservedGeneration = rc.Generation
+	setConditions(rc)
+}
+
+// setSuspended records that replication is suspended, until the next full synchronization.
+func setSuspended(rc *naisiov1.ReplicationConfig) {
+	meta.SetStatusCondition(&rc.Status.Conditions, metav1.Condition{
+		Type:               naisiov1.ConditionSuspended,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Suspended",
+		Message:            "Replication is suspended with spec.suspend",
+		ObservedGeneration: rc.Generation,
+	})
+	rc.Status.ObservedGeneration = rc.Generation
+	setConditions(rc)
+}
+
+// setNamespaceFailure records the error of a namespace, or clears it if err is nil.
+// The failures are kept sorted by namespace, so the status is stable between reconciles.
+func setNamespaceFailure(status *naisiov1.ReplicationConfigStatus, namespace string, err error) {
+	var failures []naisiov1.NamespaceFailure
+	for _, f := range status.Failures {
+		if f.Namespace != namespace {
+			failures = append(failures, f)
+		}
+	}
+	if err != nil {
+		failures = append(failures, naisiov1.NamespaceFailure{Namespace: namespace, Error: err.Error()})
+	}
+	slices.SortFunc(failures, func(a, b naisiov1.NamespaceFailure) int {
+		return strings.Compare(a.Namespace, b.Namespace)
+	})
+	status.Failures = failures
+}
+
+// conflictsInNamespace returns the conflicts in the given namespace.
+func conflictsInNamespace(conflicts []naisiov1.ResourceConflict, namespace string) []naisiov1.ResourceConflict {
+	var matching []naisiov1.ResourceConflict
+	for _, c := range conflicts {
+		if c.Namespace == namespace {
+			matching = append(matching, c)
+		}
+	}
+	return matching
+}
+
+// setNamespaceConflicts replaces the conflicts recorded for a namespace.
+func setNamespaceConflicts(status *naisiov1.ReplicationConfigStatus, namespace string, conflicts []naisiov1.ResourceConflict) {
+	var all []naisiov1.ResourceConflict
+	for _, c := range status.Conflicts {
+		if c.Namespace != namespace {
+			all = append(all, c)
+		}
+	}
+	status.Conflicts = append(all, conflicts...)
+}
+
+// setConditions derives the namespace counts and the Degraded and Ready conditions from the failures and the Synced condition.
+func setConditions(rc *naisiov1.ReplicationConfig) {
+	status := &rc.Status
+	status.FailedNamespaces = len(status.Failures)
+	status.SucceededNamespaces = max(status.TargetedNamespaces-status.FailedNamespaces, 0)
+	status.IgnoredResources = len(status.Ignored)
+
+	degraded := metav1.Condition{
+		Type:               naisiov1.ConditionDegraded,
+		Status:             metav1.ConditionFalse,
+		Reason:             "NamespacesSynchronized",
+		Message:            "No namespaces are failing",
+		ObservedGeneration: rc.Generation,
+	}
+	switch {
+	case status.FailedNamespaces > 0:
+		degraded.Status = metav1.ConditionTrue
+		degraded.Reason = "NamespacesFailed"
+		degraded.Message = fmt.Sprintf("%d of %d namespaces failed to synchronize", status.FailedNamespaces, status.TargetedNamespaces)
+	case len(status.Conflicts) > 0:
+		degraded.Status = metav1.ConditionTrue
+		degraded.Reason = "Conflicts"
+		degraded.Message = fmt.Sprintf("%d resources are owned by another ReplicationConfig", len(status.Conflicts))
+	}
+	meta.SetStatusCondition(&status.Conditions, degraded)
+
+	ready := metav1.Condition{
+		Type:               naisiov1.ConditionReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Ready",
+		Message:            "Resources are replicated to all targeted namespaces",
+		ObservedGeneration: rc.Generation,
+	}
+	switch {
+	case meta.IsStatusConditionTrue(status.Conditions, naisiov1.ConditionSuspended):
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = "Suspended"
+		ready.Message = "Replication is suspended"
+	case !meta.IsStatusConditionTrue(status.Conditions, naisiov1.ConditionSynced):
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = "NotSynced"
+		ready.Message = "The last synchronization did not succeed"
+		if synced := meta.FindStatusCondition(status.Conditions, naisiov1.ConditionSynced); synced != nil {
+			ready.Message = synced.Message
+		}
+	case degraded.Status == metav1.ConditionTrue:
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = "Degraded"
+		ready.Message = degraded.Message
+	}
+	meta.SetStatusCondition(&status.Conditions, ready)
+}
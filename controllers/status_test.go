@@ -0,0 +1,29 @@
+package controllers
+
+import (
+	"errors"
+	"testing"
+
+	naisiov1 "nais/replicator/api/v1"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetNamespaceFailure(t *testing.T) {
+	var status naisiov1.ReplicationConfigStatus
+	for _, namespace := range []string{"c", "a", "b"} {
+		setNamespaceFailure(&status, namespace, errors.New("failed"))
+	}
+	assert.Equal(t, []naisiov1.NamespaceFailure{
+		{Namespace: "a", Error: "failed"},
+		{Namespace: "b", Error: "failed"},
+		{Namespace: "c", Error: "failed"},
+	}, status.Failures)
+
+	setNamespaceFailure(&status, "b", nil)
+	setNamespaceFailure(&status, "a", errors.New("failed again"))
+	assert.Equal(t, []naisiov1.NamespaceFailure{
+		{Namespace: "a", Error: "failed again"},
+		{Namespace: "c", Error: "failed"},
+	}, status.Failures)
+}
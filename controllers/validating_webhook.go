@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -13,7 +14,13 @@ import (
 	"nais/replicator/internal/replicator"
 	"nais/replicator/internal/template"
 
+	admissionv1 "k8s.io/api/admission/v1"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
@@ -38,63 +45,258 @@ func (v *ReplicatorValidator) Handle(ctx context.Context, req admission.Request)
 	if err != nil {
 		return admission.Errored(http.StatusBadRequest, err)
 	}
-	if err := v.validateReplicationConfig(rc); err != nil {
+	// a ReplicationConfig being deleted is only updated to remove the finalizer, which must not be stopped by validation
+	if !rc.DeletionTimestamp.IsZero() {
+		return admission.Allowed("")
+	}
+	// updates that leave the spec unchanged, like adding the finalizer, are not validated again, as referenced values or
+	// the inventories of other ReplicationConfigs may have changed since and would stop the ReplicationConfig from syncing
+	if req.Operation == admissionv1.Update {
+		old := &naisiov1.ReplicationConfig{}
+		if err := v.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if equality.Semantic.DeepEqual(old.Spec, rc.Spec) {
+			return admission.Allowed("")
+		}
+	}
+
+	warnings, err := v.validateReplicationConfig(rc)
+	if err != nil {
 		return admission.Denied(err.Error())
 	}
 
-	return admission.Allowed("")
+	return admission.Allowed("").WithWarnings(warnings...)
 }
 
-func (v *ReplicatorValidator) validateReplicationConfig(rc *naisiov1.ReplicationConfig) error {
+// validateReplicationConfig returns an error if the ReplicationConfig is invalid, and warnings about what is allowed but likely a mistake.
+func (v *ReplicatorValidator) validateReplicationConfig(rc *naisiov1.ReplicationConfig) (admission.Warnings, error) {
 	if len(rc.Spec.Resources) == 0 {
-		return fmt.Errorf("no resources specified")
+		return nil, fmt.Errorf("no resources specified")
 	}
 
-	for _, resource := range rc.Spec.Resources {
+	// the templates are rendered with placeholders for the namespace, as they are not rendered for a specific one yet
+	values := replicator.NewTemplateValues(rc, v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "placeholder",
+		Labels:      map[string]string{},
+		Annotations: map[string]string{},
+	}}, map[string]string{})
+
+	var warnings admission.Warnings
+	for i, resource := range rc.Spec.Resources {
 		if resource.Template == "" {
-			return fmt.Errorf("template is empty")
+			return nil, fmt.Errorf("template is empty")
 		}
-		resource, err := template.RenderTemplate(replicator.TemplateValues{Values: map[string]string{}}, resource.Template, template.WithOption("missingkey=invalid"))
-		if err != nil {
-			return fmt.Errorf("failed to render template: %w", err)
+		if resource.NamespaceSelector != nil {
+			if _, err := metav1.LabelSelectorAsSelector(resource.NamespaceSelector); err != nil {
+				return nil, fmt.Errorf("invalid resource namespaceSelector: %w", err)
+			}
 		}
-		if resource.GetKind() == "" {
-			return fmt.Errorf("kind is empty")
+		if err := template.Parse(resource.When); err != nil {
+			return nil, fmt.Errorf("invalid when expression: %w", err)
 		}
-		if resource.GetAPIVersion() == "" {
-			return fmt.Errorf("apiVersion is empty")
+		objects, err := template.RenderTemplate(values, resource.Template, template.WithOption("missingkey=invalid"))
+		// functions like required and int fail on the values that are missing here, but not when rendered for a namespace
+		if template.IsFuncError(err) {
+			warnings = append(warnings, fmt.Sprintf("resource %d could not be rendered without values, so it is not validated: %v", i, err))
+			continue
 		}
-		if resource.GetName() == "" {
-			return fmt.Errorf("name is empty")
+		if err != nil {
+			return nil, fmt.Errorf("failed to render template: %w", err)
+		}
+		for _, object := range objects {
+			if object.GetKind() == "" {
+				return nil, fmt.Errorf("kind is empty")
+			}
+			if object.GetAPIVersion() == "" {
+				return nil, fmt.Errorf("apiVersion is empty")
+			}
+			if object.GetName() == "" {
+				return nil, fmt.Errorf("name is empty")
+			}
 		}
 	}
 
-	if err := v.validateValuesExists(context.Background(), rc); err != nil {
-		return err
+	if err := validateRollout(rc.Spec.Rollout); err != nil {
+		return nil, err
 	}
 
-	return nil
+	sourceValues, valueWarnings, err := v.validateValuesExists(context.Background(), rc)
+	if err != nil {
+		return nil, err
+	}
+	warnings = append(warnings, valueWarnings...)
+
+	overlapWarnings, err := v.validateOverlap(context.Background(), rc, sourceValues)
+	if err != nil {
+		return nil, err
+	}
+	warnings = append(warnings, overlapWarnings...)
+
+	return warnings, nil
 }
 
-func (v *ReplicatorValidator) validateValuesExists(ctx context.Context, rc *naisiov1.ReplicationConfig) error {
+// validateValuesExists checks that the referenced config maps and secrets exist, unless validation is disabled for them,
+// and warns about values set by more than one of them or the values in the spec.
+// The values of the existing ones are returned, merged with the values in the spec.
+func (v *ReplicatorValidator) validateValuesExists(ctx context.Context, rc *naisiov1.ReplicationConfig) (map[string]string, admission.Warnings, error) {
+	var configMaps []replicator.Source
+	for _, cm := range rc.Spec.TemplateValues.ConfigMaps {
+		var configMap v1.ConfigMap
+		err := v.Client.Get(ctx, client.ObjectKey{Name: cm.Name, Namespace: os.Getenv("POD_NAMESPACE")}, &configMap)
+		if err == nil {
+			configMaps = append(configMaps, replicator.ConfigMapSource(&configMap))
+			continue
+		}
+
+		if apierrors.IsNotFound(err) {
+			if cm.Validate {
+				return nil, nil, fmt.Errorf("values references non-existing config map '%s'", cm.Name)
+			}
+
+			log.Debugf("config map '%s' not found; ignoring error...", cm.Name)
+			continue
+		}
+
+		return nil, nil, fmt.Errorf("getting config map '%s': %w", cm.Name, err)
+	}
+
+	var secrets []replicator.Source
 	for _, s := range rc.Spec.TemplateValues.Secrets {
 		var secret v1.Secret
 		err := v.Client.Get(ctx, client.ObjectKey{Name: s.Name, Namespace: os.Getenv("POD_NAMESPACE")}, &secret)
 		if err == nil {
+			source, err := replicator.SecretSource(&secret, s)
+			if err != nil {
+				if s.Validate {
+					return nil, nil, err
+				}
+				log.Debugf("secret '%s' is not loadable yet; ignoring error: %v", s.Name, err)
+				continue
+			}
+			secrets = append(secrets, source)
 			continue
 		}
 
 		if apierrors.IsNotFound(err) {
 			if s.Validate {
-				return fmt.Errorf("values references non-existing secret '%s'", s.Name)
+				return nil, nil, fmt.Errorf("values references non-existing secret '%s'", s.Name)
 			}
 
 			log.Debugf("secret '%s' not found; ignoring error...", s.Name)
 			continue
 		}
 
-		return fmt.Errorf("getting secret '%s': %w", s.Name, err)
+		return nil, nil, fmt.Errorf("getting secret '%s': %w", s.Name, err)
+	}
+
+	var warnings admission.Warnings
+	values, collisions := replicator.MergeValues(rc, configMaps, secrets)
+	for _, c := range collisions {
+		warnings = append(warnings, fmt.Sprintf("value %q is set by %s, the last one is used", c.Key, strings.Join(c.Sources, ", ")))
+	}
+	return values, warnings, nil
+}
+
+// validateOverlap checks that none of the resources rendered for the namespaces selected by rc are already replicated
+// to them by another ReplicationConfig, using the inventories of the other ReplicationConfigs.
+// The resources are rendered with the values of each namespace, and only in the namespaces where another ReplicationConfig
+// has resources. A resource that cannot be rendered yet, e.g. because a secret is missing, is not checked, with a warning.
+func (v *ReplicatorValidator) validateOverlap(ctx context.Context, rc *naisiov1.ReplicationConfig, values map[string]string) (admission.Warnings, error) {
+	selector, err := metav1.LabelSelectorAsSelector(&rc.Spec.NamespaceSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid namespaceSelector: %w", err)
+	}
+	var namespaces v1.NamespaceList
+	if err := v.Client.List(ctx, &namespaces, &client.ListOptions{LabelSelector: selector}); err != nil {
+		return nil, fmt.Errorf("listing namespaces: %w", err)
+	}
+
+	var rcs naisiov1.ReplicationConfigList
+	if err := v.Client.List(ctx, &rcs); err != nil {
+		return nil, fmt.Errorf("listing ReplicationConfigs: %w", err)
+	}
+	owners := make(map[naisiov1.ResourceReference]string)
+	for _, other := range rcs.Items {
+		if other.Name == rc.Name {
+			continue
+		}
+		for _, ref := range other.Status.Resources {
+			owners[ref] = other.Name
+		}
+	}
+	if len(owners) == 0 {
+		return nil, nil
+	}
+
+	var warnings admission.Warnings
+	unrendered := make(map[int]bool)
+	scope := replicator.NewScope(values)
+	for _, ns := range namespaces.Items {
+		if excludes(ns.Annotations, rc.Name) {
+			continue
+		}
+		var refs []naisiov1.ResourceReference
+		for ref := range owners {
+			if ref.Namespace == ns.Name {
+				refs = append(refs, ref)
+			}
+		}
+		if len(refs) == 0 {
+			continue
+		}
+		replicator.SortReferences(refs)
+
+		templateValues := replicator.NewTemplateValues(rc, ns, scope.With(replicator.ExtractValues(ns, rc.Spec.TemplateValues.Namespace)).Resolve())
+		for i, resource := range rc.Spec.Resources {
+			selected, err := replicator.ForNamespace([]naisiov1.Resource{resource}, ns)
+			if err != nil {
+				return nil, err
+			}
+			objects, err := replicator.RenderResources(templateValues, selected)
+			if err != nil {
+				if !unrendered[i] {
+					unrendered[i] = true
+					warnings = append(warnings, fmt.Sprintf("resource %d could not be rendered for namespace %q, so it is not checked for overlaps with other ReplicationConfigs: %v", i, ns.Name, err))
+				}
+				continue
+			}
+			for _, object := range objects {
+				for _, ref := range refs {
+					if sameResource(object, ref) {
+						return nil, fmt.Errorf("resource %v/%v in namespace %q is already replicated by ReplicationConfig %q", ref.Kind, ref.Name, ref.Namespace, owners[ref])
+					}
+				}
+			}
+		}
 	}
+	return warnings, nil
+}
 
+// sameResource reports whether the rendered resource has the group, kind and name of the inventory entry, in any namespace.
+func sameResource(resource *unstructured.Unstructured, ref naisiov1.ResourceReference) bool {
+	gvk := schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind)
+	return resource.GroupVersionKind().GroupKind() == gvk.GroupKind() && resource.GetName() == ref.Name
+}
+
+func validateRollout(rollout *naisiov1.Rollout) error {
+	if rollout == nil {
+		return nil
+	}
+	if rollout.Canary != nil {
+		if _, err := metav1.LabelSelectorAsSelector(rollout.Canary); err != nil {
+			return fmt.Errorf("invalid rollout canary selector: %w", err)
+		}
+	}
+	if rollout.BatchSize != nil {
+		if _, err := intstr.GetScaledValueFromIntOrPercent(rollout.BatchSize, 100, true); err != nil {
+			return fmt.Errorf("invalid rollout batchSize: %w", err)
+		}
+	}
+	if rollout.MaxFailures != nil {
+		if _, err := intstr.GetScaledValueFromIntOrPercent(rollout.MaxFailures, 100, false); err != nil {
+			return fmt.Errorf("invalid rollout maxFailures: %w", err)
+		}
+	}
 	return nil
 }
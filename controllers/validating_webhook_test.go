@@ -0,0 +1,220 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	naisiov1 "nais/replicator/api/v1"
+
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func newValidator(t *testing.T, objects ...client.Object) *ReplicatorValidator {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, clientgoscheme.AddToScheme(scheme))
+	assert.NoError(t, naisiov1.AddToScheme(scheme))
+	return &ReplicatorValidator{
+		Client:  fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(),
+		decoder: admission.NewDecoder(scheme),
+	}
+}
+
+func TestValidateFunctionsOnValues(t *testing.T) {
+	v := newValidator(t)
+
+	for _, tpl := range []string{
+		`[[ required "team is required" .Values.team ]]`,
+		`[[ .Values.replicas | int ]]`,
+		`[[ add .Values.replicas 1 ]]`,
+	} {
+		t.Run(tpl, func(t *testing.T) {
+			rc := &naisiov1.ReplicationConfig{Spec: naisiov1.ReplicationConfigSpec{Resources: []naisiov1.Resource{{Template: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  value: "` + tpl + `"
+`}}}}
+			warnings, err := v.validateReplicationConfig(rc)
+			assert.NoError(t, err)
+			assert.Len(t, warnings, 1)
+		})
+	}
+}
+
+func TestValidateInvalidTemplate(t *testing.T) {
+	v := newValidator(t)
+
+	for _, tpl := range []string{
+		"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: [[ .Values.name ",
+		"apiVersion: v1\nkind: ConfigMap\nmetadata: [",
+		"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  labels: {}\n",
+		// unknown fields and wrong arguments are not caused by missing values
+		"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: [[ .Namspace.Name ]]\n",
+		"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: [[ index .Config 1 ]]\n",
+		"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a[[ printf ]]\n",
+		"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: [[ trimPrefix 1 \"a\" ]]\n",
+		"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: [[ add ]]\n",
+	} {
+		rc := &naisiov1.ReplicationConfig{Spec: naisiov1.ReplicationConfigSpec{Resources: []naisiov1.Resource{{Template: tpl}}}}
+		_, err := v.validateReplicationConfig(rc)
+		assert.Error(t, err)
+	}
+}
+
+func TestValidateOverlap(t *testing.T) {
+	v := newValidator(t,
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "prod", Labels: map[string]string{"env": "prod"}}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "dev", Labels: map[string]string{"env": "dev"}}},
+		&naisiov1.ReplicationConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "a"},
+			Status: naisiov1.ReplicationConfigStatus{Resources: []naisiov1.ResourceReference{
+				{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "RoleBinding", Name: "x", Namespace: "prod"},
+			}},
+		},
+	)
+
+	rc := func(env string) *naisiov1.ReplicationConfig {
+		return &naisiov1.ReplicationConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "b"},
+			Spec: naisiov1.ReplicationConfigSpec{Resources: []naisiov1.Resource{{
+				NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": env}},
+				Template:          "apiVersion: rbac.authorization.k8s.io/v1\nkind: RoleBinding\nmetadata:\n  name: x\n",
+			}}},
+		}
+	}
+
+	_, err := v.validateReplicationConfig(rc("dev"))
+	assert.NoError(t, err)
+
+	_, err = v.validateReplicationConfig(rc("prod"))
+	assert.Error(t, err)
+}
+
+func TestValidateOverlapRenderedNames(t *testing.T) {
+	v := newValidator(t,
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "prod", Labels: map[string]string{"team": "a"}}},
+		&naisiov1.ReplicationConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "a"},
+			Status: naisiov1.ReplicationConfigStatus{Resources: []naisiov1.ResourceReference{
+				{APIVersion: "v1", Kind: "ConfigMap", Name: "a-prod", Namespace: "prod"},
+			}},
+		},
+	)
+
+	rc := func(when string) *naisiov1.ReplicationConfig {
+		return &naisiov1.ReplicationConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "b"},
+			Spec: naisiov1.ReplicationConfigSpec{
+				TemplateValues: naisiov1.TemplateValues{Namespace: naisiov1.Namespace{Labels: []string{"team"}}},
+				Resources: []naisiov1.Resource{{
+					When:     when,
+					Template: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: [[ .Values.team ]]-[[ .Namespace.Name ]]\n",
+				}},
+			},
+		}
+	}
+
+	// the name is rendered with the values of the namespace
+	_, err := v.validateReplicationConfig(rc(""))
+	assert.Error(t, err)
+
+	// the resource is not rendered for the namespace
+	_, err = v.validateReplicationConfig(rc(`[[ eq .Values.team "b" ]]`))
+	assert.NoError(t, err)
+
+	// a value that is missing until the secret is created is reported
+	missing := rc("")
+	missing.Spec.Resources[0].Template = "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: [[ .Values.missing ]]\n"
+	warnings, err := v.validateReplicationConfig(missing)
+	assert.NoError(t, err)
+	assert.Len(t, warnings, 1)
+}
+
+func TestValidateValueCollisions(t *testing.T) {
+	t.Setenv("POD_NAMESPACE", "replicator")
+	v := newValidator(t,
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "shared", Namespace: "replicator"}, Data: map[string]string{"project": "shared"}},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "replicator"}, Data: map[string][]byte{"password": []byte("secret")}},
+	)
+
+	rc := &naisiov1.ReplicationConfig{Spec: naisiov1.ReplicationConfigSpec{
+		TemplateValues: naisiov1.TemplateValues{
+			Values:     map[string]string{"project": "spec", "password": "spec"},
+			ConfigMaps: []naisiov1.ConfigMap{{Name: "shared", Validate: true}},
+			Secrets:    []naisiov1.Secret{{Name: "db", Validate: true}},
+		},
+		Resources: []naisiov1.Resource{{Template: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\n"}},
+	}}
+	warnings, err := v.validateReplicationConfig(rc)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		`value "password" is set by values, secret/db, the last one is used`,
+		`value "project" is set by values, configMap/shared, the last one is used`,
+	}, []string(warnings))
+}
+
+func updateRequest(t *testing.T, old, rc *naisiov1.ReplicationConfig) admission.Request {
+	oldRaw, err := json.Marshal(old)
+	assert.NoError(t, err)
+	raw, err := json.Marshal(rc)
+	assert.NoError(t, err)
+	return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: admissionv1.Update,
+		OldObject: runtime.RawExtension{Raw: oldRaw},
+		Object:    runtime.RawExtension{Raw: raw},
+	}}
+}
+
+func TestHandleUnchangedSpec(t *testing.T) {
+	t.Setenv("POD_NAMESPACE", "replicator")
+	template := "apiVersion: rbac.authorization.k8s.io/v1\nkind: RoleBinding\nmetadata:\n  name: x\n"
+	v := newValidator(t,
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team"}},
+		&naisiov1.ReplicationConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "b"},
+			Status: naisiov1.ReplicationConfigStatus{Resources: []naisiov1.ResourceReference{
+				{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "RoleBinding", Name: "x", Namespace: "team"},
+			}},
+		},
+	)
+
+	for name, spec := range map[string]naisiov1.ReplicationConfigSpec{
+		// another ReplicationConfig has replicated the resource since the ReplicationConfig was admitted
+		"overlap": {Resources: []naisiov1.Resource{{Template: template}}},
+		// the referenced secret has been deleted since the ReplicationConfig was admitted
+		"deleted secret": {
+			TemplateValues: naisiov1.TemplateValues{Secrets: []naisiov1.Secret{{Name: "deleted", Validate: true}}},
+			Resources:      []naisiov1.Resource{{Template: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\n"}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			old := &naisiov1.ReplicationConfig{
+				TypeMeta:   metav1.TypeMeta{APIVersion: naisiov1.GroupVersion.String(), Kind: "ReplicationConfig"},
+				ObjectMeta: metav1.ObjectMeta{Name: "a"},
+				Spec:       spec,
+			}
+			_, err := v.validateReplicationConfig(old)
+			assert.Error(t, err)
+
+			// adding the finalizer is allowed
+			rc := old.DeepCopy()
+			rc.Finalizers = []string{finalizer}
+			assert.True(t, v.Handle(context.Background(), updateRequest(t, old, rc)).Allowed)
+
+			// changing the spec is validated
+			rc.Spec.Suspend = true
+			assert.False(t, v.Handle(context.Background(), updateRequest(t, old, rc)).Allowed)
+		})
+	}
+}
@@ -1,7 +1,10 @@
 package content
 
 import (
+	b64 "encoding/base64"
 	"fmt"
+	"reflect"
+
 	"github.com/mitchellh/hashstructure/v2"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
@@ -9,27 +12,61 @@ import (
 const (
 	SpecContent       = "spec"
 	DataContent       = "data"
+	BinaryDataContent = "binaryData"
 	StringDataContent = "stringData"
 )
 
+// ignoredFields are populated by the server or identify the resource, and are not compared.
+var ignoredFields = map[string]bool{
+	"apiVersion": true,
+	"kind":       true,
+	"metadata":   true,
+	"status":     true,
+}
+
+// exactFields are compared in full, as the server does not default any keys in them.
+// Other fields only compare what is rendered, so that fields defaulted by the server are not seen as changes.
+var exactFields = map[string]bool{
+	DataContent:       true,
+	BinaryDataContent: true,
+}
+
+// LastAppliedAnnotation holds a hash of the fields, labels and annotations of the template the resource was last written from.
+// They are not compared in full, so the hash is how keys removed from the template are detected.
+const LastAppliedAnnotation = "replicator.nais.io/last-applied"
+
 type ResourceContent interface {
-	Annotations() string
-	Labels() string
-	Equals(content ResourceContent) bool
+	// Annotations returns the annotations, except LastAppliedAnnotation.
+	Annotations() map[string]string
+	Labels() map[string]string
+	// Fields returns the top-level fields of the resource, except metadata and status.
+	Fields() map[string]any
+	// Hash returns a hash of the fields, labels and annotations.
 	Hash() string
+	// LastApplied returns the value of LastAppliedAnnotation.
+	LastApplied() string
+	// Equals reports whether every field, label and annotation of this content is found with the same value in the given content,
+	// and the given content was last applied from the same template.
+	Equals(content ResourceContent) bool
 }
 
 func Get(data *unstructured.Unstructured) (ResourceContent, error) {
-	switch {
-	case data.UnstructuredContent()[SpecContent] != nil:
-		return NewSpec(data)
-	case data.UnstructuredContent()[DataContent] != nil:
-		return NewData(data)
-	case data.UnstructuredContent()[StringDataContent] != nil:
-		return NewStringData(data)
-	default:
-		return NewGeneric(data)
+	return NewObject(data)
+}
+
+// SetLastApplied sets LastAppliedAnnotation on a rendered resource, so the fields it is written with can be compared later.
+func SetLastApplied(data *unstructured.Unstructured) error {
+	o, err := NewObject(data)
+	if err != nil {
+		return err
+	}
+	annotations := data.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
 	}
+	annotations[LastAppliedAnnotation] = o.Hash()
+	data.SetAnnotations(annotations)
+	return nil
 }
 
 func toHash(input any) (string, error) {
@@ -40,10 +77,100 @@ func toHash(input any) (string, error) {
 	return fmt.Sprintf("%x", hash), nil
 }
 
-func getContent(data *unstructured.Unstructured, contentType string) (map[string]interface{}, error) {
-	content := data.UnstructuredContent()[contentType]
-	if content == nil {
-		return nil, fmt.Errorf("content type %q not found with data %v", contentType, data.UnstructuredContent())
+// withStringData merges stringData into data the way the server does, as stringData is never returned from the server.
+func withStringData(fields map[string]any) map[string]any {
+	stringData, ok := fields[StringDataContent].(map[string]any)
+	if !ok {
+		return fields
+	}
+
+	data := make(map[string]any)
+	if existing, ok := fields[DataContent].(map[string]any); ok {
+		for k, v := range existing {
+			data[k] = v
+		}
+	}
+	for k, v := range stringData {
+		data[k] = b64.StdEncoding.EncodeToString([]byte(fmt.Sprint(v)))
+	}
+
+	delete(fields, StringDataContent)
+	fields[DataContent] = data
+	return fields
+}
+
+// contains reports whether every value in desired is found in live.
+func contains(live, desired any) bool {
+	switch d := desired.(type) {
+	case map[string]any:
+		l, ok := live.(map[string]any)
+		if !ok {
+			return live == nil && isEmpty(d)
+		}
+		for k, dv := range d {
+			lv, ok := l[k]
+			if !ok {
+				if isEmpty(dv) {
+					continue
+				}
+				return false
+			}
+			if !contains(lv, dv) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		l, ok := live.([]any)
+		if !ok {
+			return live == nil && isEmpty(d)
+		}
+		if len(l) != len(d) {
+			return false
+		}
+		for i := range d {
+			if !contains(l[i], d[i]) {
+				return false
+			}
+		}
+		return true
+	case nil:
+		return isEmpty(live)
+	default:
+		if dn, ok := number(desired); ok {
+			ln, ok := number(live)
+			return ok && dn == ln
+		}
+		return reflect.DeepEqual(live, desired)
+	}
+}
+
+// isEmpty reports whether the value is omitted by the server when stored.
+func isEmpty(v any) bool {
+	switch t := v.(type) {
+	case nil:
+		return true
+	case map[string]any:
+		return len(t) == 0
+	case []any:
+		return len(t) == 0
+	}
+	return false
+}
+
+// number converts numeric values, as rendered templates hold int while resources from the server hold int64 or float64.
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
 	}
-	return content.(map[string]interface{}), nil
+	return 0, false
 }
@@ -172,8 +172,7 @@ func TestContentHasChanged(t *testing.T) {
 			),
 		},
 		{
-			name:           "existingData annotations has changed, it should return true",
-			expectedChange: true,
+			name: "existingData has extra annotations, it should return false",
 			existingData: unstructuredData(SpecContent, map[string]interface{}{},
 				true,
 				false,
@@ -198,8 +197,7 @@ func TestContentHasChanged(t *testing.T) {
 		},
 
 		{
-			name:           "existingData labels has changed, it should return true",
-			expectedChange: true,
+			name: "existingData has extra labels, it should return false",
 			existingData: unstructuredData(SpecContent, map[string]interface{}{},
 				false,
 				true,
@@ -233,8 +231,7 @@ func TestContentHasChanged(t *testing.T) {
 			),
 		},
 		{
-			name:           "existingData annotations has changed, it should return true",
-			expectedChange: true,
+			name: "existingData has extra annotations, it should return false",
 			existingData: unstructuredDataWithoutContent(
 				true,
 				false,
@@ -259,8 +256,7 @@ func TestContentHasChanged(t *testing.T) {
 		},
 
 		{
-			name:           "existingData labels has changed, it should return true",
-			expectedChange: true,
+			name: "existingData has extra labels, it should return false",
 			existingData: unstructuredDataWithoutContent(
 				false,
 				true,
@@ -277,6 +273,8 @@ func TestContentHasChanged(t *testing.T) {
 				assert.Error(t, err)
 				return
 			}
+			// the existing resource was written from the rendered template
+			setLastApplied(tt.existingData, rcContent.Hash())
 			existingContent, err := Get(tt.existingData)
 			if tt.expectedError {
 				assert.Error(t, err)
@@ -349,3 +347,220 @@ func unstructuredData(contentKey string, contentValues map[string]interface{}, a
 		},
 	}
 }
+
+func TestFieldsHaveChanged(t *testing.T) {
+	for _, tt := range []struct {
+		name           string
+		existingData   map[string]interface{}
+		rcInput        map[string]interface{}
+		lastApplied    map[string]interface{} // the template existingData was written from, rcInput if nil
+		expectedChange bool
+	}{
+		{
+			name: "Role 'rules' has not changed, it should return false",
+			existingData: map[string]interface{}{
+				"rules": []interface{}{
+					map[string]interface{}{"apiGroups": []interface{}{""}, "resources": []interface{}{"pods"}, "verbs": []interface{}{"get"}},
+				},
+			},
+			rcInput: map[string]interface{}{
+				"rules": []interface{}{
+					map[string]interface{}{"apiGroups": []interface{}{""}, "resources": []interface{}{"pods"}, "verbs": []interface{}{"get"}},
+				},
+			},
+		},
+		{
+			name:           "rcInput Role 'rules' has changed, it should return true",
+			expectedChange: true,
+			existingData: map[string]interface{}{
+				"rules": []interface{}{
+					map[string]interface{}{"apiGroups": []interface{}{""}, "resources": []interface{}{"pods"}, "verbs": []interface{}{"get"}},
+				},
+			},
+			rcInput: map[string]interface{}{
+				"rules": []interface{}{
+					map[string]interface{}{"apiGroups": []interface{}{""}, "resources": []interface{}{"pods"}, "verbs": []interface{}{"get", "list"}},
+				},
+			},
+		},
+		{
+			name:           "rcInput RoleBinding 'subjects' has changed, it should return true",
+			expectedChange: true,
+			existingData: map[string]interface{}{
+				"subjects": []interface{}{
+					map[string]interface{}{"kind": "Group", "name": "team-a"},
+				},
+			},
+			rcInput: map[string]interface{}{
+				"subjects": []interface{}{
+					map[string]interface{}{"kind": "Group", "name": "team-a"},
+					map[string]interface{}{"kind": "Group", "name": "team-b"},
+				},
+			},
+		},
+		{
+			name:           "rcInput 'type' has changed, it should return true",
+			expectedChange: true,
+			existingData:   map[string]interface{}{"type": "Opaque"},
+			rcInput:        map[string]interface{}{"type": "kubernetes.io/tls"},
+		},
+		{
+			name: "existingData 'type' is not rendered, it should return false",
+			existingData: map[string]interface{}{
+				"type": "Opaque",
+				"data": map[string]interface{}{"key": "dmFsdWU="},
+			},
+			rcInput: map[string]interface{}{
+				"data": map[string]interface{}{"key": "dmFsdWU="},
+			},
+		},
+		{
+			name:           "existingData 'binaryData' changed, it should return true",
+			expectedChange: true,
+			existingData: map[string]interface{}{
+				"binaryData": map[string]interface{}{"key": "dmFsdWU=", "other-key": "dmFsdWU="},
+			},
+			rcInput: map[string]interface{}{
+				"binaryData": map[string]interface{}{"key": "dmFsdWU="},
+			},
+		},
+		{
+			name: "'data' and 'stringData' are merged, it should return false",
+			existingData: map[string]interface{}{
+				"data": map[string]interface{}{
+					"key":       base64.StdEncoding.EncodeToString([]byte("my-value")),
+					"other-key": base64.StdEncoding.EncodeToString([]byte("my-other-value")),
+				},
+			},
+			rcInput: map[string]interface{}{
+				"data":       map[string]interface{}{"other-key": base64.StdEncoding.EncodeToString([]byte("my-other-value"))},
+				"stringData": map[string]interface{}{"key": "my-value"},
+			},
+		},
+		{
+			name: "existingData has server defaulted fields, it should return false",
+			existingData: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"replicas": int64(1),
+					"strategy": map[string]interface{}{"type": "RollingUpdate"},
+				},
+			},
+			rcInput: map[string]interface{}{
+				"spec": map[string]interface{}{"replicas": 1},
+			},
+		},
+		{
+			name:         "rcInput has empty fields omitted by the server, it should return false",
+			existingData: map[string]interface{}{},
+			rcInput: map[string]interface{}{
+				"data":  map[string]interface{}{},
+				"rules": []interface{}{},
+			},
+		},
+		{
+			name:           "rcInput has removed a field, it should return true",
+			expectedChange: true,
+			existingData: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"podSelector": map[string]interface{}{},
+					"egress":      []interface{}{map[string]interface{}{"ports": []interface{}{map[string]interface{}{"port": int64(53)}}}},
+				},
+			},
+			lastApplied: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"podSelector": map[string]interface{}{},
+					"egress":      []interface{}{map[string]interface{}{"ports": []interface{}{map[string]interface{}{"port": 53}}}},
+				},
+			},
+			rcInput: map[string]interface{}{
+				"spec": map[string]interface{}{"podSelector": map[string]interface{}{}},
+			},
+		},
+		{
+			name: "existingData 'status' changed, it should return false",
+			existingData: map[string]interface{}{
+				"spec":   map[string]interface{}{"replicas": int64(1)},
+				"status": map[string]interface{}{"ready": true},
+			},
+			rcInput: map[string]interface{}{
+				"spec": map[string]interface{}{"replicas": 1},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rcContent, err := Get(unstructuredFields(tt.rcInput))
+			assert.NoError(t, err)
+			lastApplied := tt.rcInput
+			if tt.lastApplied != nil {
+				lastApplied = tt.lastApplied
+			}
+			lastAppliedContent, err := Get(unstructuredFields(lastApplied))
+			assert.NoError(t, err)
+			existing := unstructuredFields(tt.existingData)
+			setLastApplied(existing, lastAppliedContent.Hash())
+			existingContent, err := Get(existing)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedChange, !rcContent.Equals(existingContent))
+		})
+	}
+}
+
+func TestLastApplied(t *testing.T) {
+	rendered := unstructuredFields(map[string]interface{}{"spec": map[string]interface{}{"replicas": 1}})
+	assert.NoError(t, SetLastApplied(rendered))
+	rcContent, err := Get(rendered)
+	assert.NoError(t, err)
+	assert.Equal(t, rcContent.Hash(), rcContent.LastApplied())
+
+	// the annotation is not compared as an annotation
+	existing := unstructuredFields(map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(1)}})
+	existingContent, err := Get(existing)
+	assert.NoError(t, err)
+	assert.Equal(t, rcContent.Annotations(), existingContent.Annotations())
+
+	// labels and annotations added by others are not compared
+	existing.SetAnnotations(map[string]string{"policies.kyverno.io/last-applied-patches": "patch"})
+	existing.SetLabels(map[string]string{"added-by": "operator"})
+	existingContent, err = Get(existing)
+	assert.NoError(t, err)
+	assert.NotEqual(t, rcContent.Annotations(), existingContent.Annotations())
+
+	// a resource written before the annotation was introduced is written again to set it
+	assert.False(t, rcContent.Equals(existingContent))
+	setLastApplied(existing, rcContent.Hash())
+	existingContent, err = Get(existing)
+	assert.NoError(t, err)
+	assert.True(t, rcContent.Equals(existingContent))
+
+	// an annotation removed from the template is removed from the resource
+	previous := unstructuredFields(map[string]interface{}{"spec": map[string]interface{}{"replicas": 1}})
+	previous.SetAnnotations(map[string]string{"removed": "value"})
+	assert.NoError(t, SetLastApplied(previous))
+	existing.SetAnnotations(previous.GetAnnotations())
+	existingContent, err = Get(existing)
+	assert.NoError(t, err)
+	assert.False(t, rcContent.Equals(existingContent))
+}
+
+func setLastApplied(data *unstructured.Unstructured, hash string) {
+	annotations := data.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[LastAppliedAnnotation] = hash
+	data.SetAnnotations(annotations)
+}
+
+func unstructuredFields(fields map[string]interface{}) *unstructured.Unstructured {
+	object := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Test",
+		"metadata": map[string]interface{}{
+			"name": "test",
+		},
+	}
+	for k, v := range fields {
+		object[k] = v
+	}
+	return &unstructured.Unstructured{Object: object}
+}
@@ -0,0 +1,108 @@
+package content
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Object is the content of any kind of resource: every top-level field except metadata and status, and its labels and annotations.
+type Object struct {
+	fields      map[string]any
+	hash        string
+	annotations map[string]string
+	labels      map[string]string
+	lastApplied string
+}
+
+func NewObject(data *unstructured.Unstructured) (*Object, error) {
+	fields := make(map[string]any)
+	for k, v := range data.UnstructuredContent() {
+		if !ignoredFields[k] {
+			fields[k] = v
+		}
+	}
+	fields = withStringData(fields)
+
+	annotations := make(map[string]string)
+	for k, v := range data.GetAnnotations() {
+		if k != LastAppliedAnnotation {
+			annotations[k] = v
+		}
+	}
+	labels := data.GetLabels()
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+
+	// labels and annotations are only compared for the rendered keys, so a key removed from the template changes the hash as well
+	hash, err := toHash(struct {
+		Fields      map[string]any
+		Labels      map[string]string
+		Annotations map[string]string
+	}{fields, labels, annotations})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Object{
+		fields:      fields,
+		hash:        hash,
+		annotations: annotations,
+		labels:      labels,
+		lastApplied: data.GetAnnotations()[LastAppliedAnnotation],
+	}, nil
+}
+
+func (o *Object) Equals(content ResourceContent) bool {
+	// labels and annotations added by others, e.g. mutating webhooks, are left alone, as they would be added back after every update
+	if !containsStrings(content.Labels(), o.labels) || !containsStrings(content.Annotations(), o.annotations) {
+		return false
+	}
+	// fields removed from the template are not found by comparing the rendered fields, but change the hash
+	if o.hash != content.LastApplied() {
+		return false
+	}
+
+	existing := content.Fields()
+	for k, v := range o.fields {
+		if exactFields[k] {
+			if !contains(existing[k], v) || !contains(v, existing[k]) {
+				return false
+			}
+			continue
+		}
+		if !contains(existing[k], v) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsStrings reports whether every key in desired is found with the same value in live.
+func containsStrings(live, desired map[string]string) bool {
+	for k, v := range desired {
+		if lv, ok := live[k]; !ok || lv != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (o *Object) Fields() map[string]any {
+	return o.fields
+}
+
+func (o *Object) Hash() string {
+	return o.hash
+}
+
+func (o *Object) LastApplied() string {
+	return o.lastApplied
+}
+
+func (o *Object) Annotations() map[string]string {
+	return o.annotations
+}
+
+func (o *Object) Labels() map[string]string {
+	return o.labels
+}
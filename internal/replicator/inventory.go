@@ -0,0 +1,102 @@
+package replicator
+
+import (
+	"sort"
+
+	naisiov1 "nais/replicator/api/v1"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Reference returns the inventory entry of a replicated resource.
+func Reference(resource *unstructured.Unstructured) naisiov1.ResourceReference {
+	return naisiov1.ResourceReference{
+		Namespace:  resource.GetNamespace(),
+		APIVersion: resource.GetAPIVersion(),
+		Kind:       resource.GetKind(),
+		Name:       resource.GetName(),
+	}
+}
+
+// Object returns an empty object identified by the inventory entry, suitable for get and delete calls.
+func Object(ref naisiov1.ResourceReference) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion(ref.APIVersion)
+	u.SetKind(ref.Kind)
+	u.SetNamespace(ref.Namespace)
+	u.SetName(ref.Name)
+	return u
+}
+
+// Stale returns the entries of previous that are not present in current.
+// Entries are compared without the version, as a resource whose template moves to another version of its kind is the same object.
+func Stale(previous, current []naisiov1.ResourceReference) []naisiov1.ResourceReference {
+	existing := make(map[resourceKey]bool, len(current))
+	for _, ref := range current {
+		existing[keyOf(ref)] = true
+	}
+
+	var stale []naisiov1.ResourceReference
+	for _, ref := range previous {
+		if !existing[keyOf(ref)] {
+			stale = append(stale, ref)
+		}
+	}
+	return stale
+}
+
+// resourceKey identifies a resource regardless of the version it is referred to with.
+type resourceKey struct {
+	groupKind       schema.GroupKind
+	namespace, name string
+}
+
+func keyOf(ref naisiov1.ResourceReference) resourceKey {
+	return resourceKey{
+		groupKind: schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind).GroupKind(),
+		namespace: ref.Namespace,
+		name:      ref.Name,
+	}
+}
+
+// SortReferences sorts the inventory by namespace, apiVersion, kind and name to keep the status stable between reconciles.
+func SortReferences(refs []naisiov1.ResourceReference) {
+	sort.Slice(refs, func(i, j int) bool {
+		a, b := refs[i], refs[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.APIVersion != b.APIVersion {
+			return a.APIVersion < b.APIVersion
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+}
+
+// InNamespace returns the entries of the inventory in the given namespace.
+func InNamespace(refs []naisiov1.ResourceReference, namespace string) []naisiov1.ResourceReference {
+	var matching []naisiov1.ResourceReference
+	for _, ref := range refs {
+		if ref.Namespace == namespace {
+			matching = append(matching, ref)
+		}
+	}
+	return matching
+}
+
+// ReplaceNamespace returns a sorted copy of the inventory where the entries of the given namespace are replaced by current.
+func ReplaceNamespace(refs []naisiov1.ResourceReference, namespace string, current []naisiov1.ResourceReference) []naisiov1.ResourceReference {
+	var inventory []naisiov1.ResourceReference
+	for _, ref := range refs {
+		if ref.Namespace != namespace {
+			inventory = append(inventory, ref)
+		}
+	}
+	inventory = append(inventory, current...)
+	SortReferences(inventory)
+	return inventory
+}
@@ -1,9 +1,7 @@
 package replicator
 
 import (
-	"context"
 	"fmt"
-	"os"
 	"strings"
 
 	naisiov1 "nais/replicator/api/v1"
@@ -11,39 +9,89 @@ import (
 
 	hashstructure "github.com/mitchellh/hashstructure/v2"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"sigs.k8s.io/controller-runtime/pkg/client"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
+// TemplateValues is the data templates are rendered with.
 type TemplateValues struct {
 	Values map[string]string
+	// Namespace is the namespace the resources are rendered for.
+	Namespace NamespaceValues
+	// Config is the ReplicationConfig the resources are rendered by.
+	Config ConfigValues
 }
 
+type NamespaceValues struct {
+	Name        string
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+type ConfigValues struct {
+	Name string
+}
+
+// NewTemplateValues returns the data to render the templates of the ReplicationConfig for the namespace.
+func NewTemplateValues(rc *naisiov1.ReplicationConfig, namespace v1.Namespace, values map[string]string) *TemplateValues {
+	return &TemplateValues{
+		Values: values,
+		Namespace: NamespaceValues{
+			Name:        namespace.Name,
+			Labels:      namespace.Labels,
+			Annotations: namespace.Annotations,
+		},
+		Config: ConfigValues{
+			Name: rc.Name,
+		},
+	}
+}
+
+// RenderResources renders the templates of the resources, each of which may render any number of objects.
+// Resources with a when expression that does not render true are skipped.
 func RenderResources(values *TemplateValues, resources []naisiov1.Resource) ([]*unstructured.Unstructured, error) {
 	var objects []*unstructured.Unstructured
 	for _, r := range resources {
-		resource, err := template.RenderTemplate(values, r.Template)
+		if r.When != "" {
+			ok, err := template.RenderCondition(values, r.When)
+			if err != nil {
+				return nil, fmt.Errorf("evaluating when: %w", err)
+			}
+			if !ok {
+				continue
+			}
+		}
+		rendered, err := template.RenderTemplate(values, r.Template)
 		if err != nil {
 			return nil, err
 		}
-		objects = append(objects, resource)
+		objects = append(objects, rendered...)
 	}
 	return objects, nil
 }
 
-func ExtractValues(namespace v1.Namespace, namespaceValues naisiov1.Namespace) map[string]string {
-	labels := filter(namespace.Labels, namespaceValues.Labels)
-	return Merge(labels, filter(namespace.Annotations, namespaceValues.Annotations))
+// ForNamespace returns the resources whose namespaceSelector matches the namespace, or that have none.
+func ForNamespace(resources []naisiov1.Resource, namespace v1.Namespace) ([]naisiov1.Resource, error) {
+	var selected []naisiov1.Resource
+	for _, r := range resources {
+		if r.NamespaceSelector != nil {
+			selector, err := metav1.LabelSelectorAsSelector(r.NamespaceSelector)
+			if err != nil {
+				return nil, fmt.Errorf("parsing namespaceSelector: %w", err)
+			}
+			if !selector.Matches(labels.Set(namespace.Labels)) {
+				continue
+			}
+		}
+		selected = append(selected, r)
+	}
+	return selected, nil
 }
 
-func Merge(a, b map[string]string) map[string]string {
-	if a == nil {
-		return b
-	}
-	for k, v := range b {
-		a[k] = v
-	}
-	return a
+func ExtractValues(namespace v1.Namespace, namespaceValues naisiov1.Namespace) map[string]string {
+	labels := filter(namespace.Labels, namespaceValues.Labels)
+	return NewScope(labels, filter(namespace.Annotations, namespaceValues.Annotations)).Resolve()
 }
 
 func filter(m map[string]string, keys []string) map[string]string {
@@ -63,24 +111,17 @@ func normalizeKey(key string) string {
 	return key
 }
 
-func LoadSecrets(ctx context.Context, c client.Client, rc *naisiov1.ReplicationConfig) (map[string]string, error) {
-	values := make(map[string]string)
-	for _, s := range rc.Spec.TemplateValues.Secrets {
-
-		var secret v1.Secret
-		if err := c.Get(ctx, client.ObjectKey{Name: s.Name, Namespace: os.Getenv("POD_NAMESPACE")}, &secret); err != nil {
-			return nil, err
-		}
-
-		for k, v := range secret.Data {
-			values[k] = string(v)
-		}
+// Hash returns the synchronization hash of the spec and the values loaded from its referenced sources,
+// so that a change in a referenced secret triggers a new synchronization.
+func Hash(spec *naisiov1.ReplicationConfigSpec, values map[string]string) (string, error) {
+	input := struct {
+		Spec   *naisiov1.ReplicationConfigSpec
+		Values map[string]string
+	}{
+		Spec:   spec,
+		Values: values,
 	}
-	return values, nil
-}
-
-func Hash(rc *naisiov1.ReplicationConfigSpec) (string, error) {
-	hash, err := hashstructure.Hash(rc, hashstructure.FormatV2, nil)
+	hash, err := hashstructure.Hash(input, hashstructure.FormatV2, nil)
 	if err != nil {
 		return "", err
 	}
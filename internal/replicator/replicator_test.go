@@ -1,6 +1,7 @@
 package replicator
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"testing"
@@ -9,6 +10,8 @@ import (
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/yaml.v2"
@@ -60,3 +63,268 @@ func TestExtractValues(t *testing.T) {
 	assert.Equal(t, "", values["some.url.io/key"])
 	assert.Equal(t, "annotation_value", values["key"])
 }
+
+func TestStale(t *testing.T) {
+	secret := naisiov1.ResourceReference{Namespace: "a", APIVersion: "v1", Kind: "Secret", Name: "foo"}
+	configMap := naisiov1.ResourceReference{Namespace: "a", APIVersion: "v1", Kind: "ConfigMap", Name: "foo"}
+	otherNamespace := naisiov1.ResourceReference{Namespace: "b", APIVersion: "v1", Kind: "Secret", Name: "foo"}
+
+	previous := []naisiov1.ResourceReference{secret, configMap, otherNamespace}
+	current := []naisiov1.ResourceReference{secret, otherNamespace}
+
+	assert.Equal(t, []naisiov1.ResourceReference{configMap}, Stale(previous, current))
+	assert.Empty(t, Stale(current, previous))
+	assert.Empty(t, Stale(nil, current))
+
+	// a resource moved to another version of its kind is not stale
+	v1beta1 := naisiov1.ResourceReference{Namespace: "a", APIVersion: "example.com/v1beta1", Kind: "Widget", Name: "foo"}
+	v1 := naisiov1.ResourceReference{Namespace: "a", APIVersion: "example.com/v1", Kind: "Widget", Name: "foo"}
+	otherGroup := naisiov1.ResourceReference{Namespace: "a", APIVersion: "other.com/v1", Kind: "Widget", Name: "foo"}
+	assert.Empty(t, Stale([]naisiov1.ResourceReference{v1beta1}, []naisiov1.ResourceReference{v1}))
+	assert.Equal(t, []naisiov1.ResourceReference{v1beta1}, Stale([]naisiov1.ResourceReference{v1beta1}, []naisiov1.ResourceReference{otherGroup}))
+}
+
+func TestSortReferences(t *testing.T) {
+	refs := []naisiov1.ResourceReference{
+		{Namespace: "b", APIVersion: "v1", Kind: "Secret", Name: "foo"},
+		{Namespace: "a", APIVersion: "v1", Kind: "Secret", Name: "foo"},
+		{Namespace: "a", APIVersion: "v1", Kind: "ConfigMap", Name: "foo"},
+	}
+
+	SortReferences(refs)
+
+	assert.Equal(t, "ConfigMap", refs[0].Kind)
+	assert.Equal(t, "a", refs[1].Namespace)
+	assert.Equal(t, "b", refs[2].Namespace)
+}
+
+func TestReplaceNamespace(t *testing.T) {
+	a := naisiov1.ResourceReference{Namespace: "a", APIVersion: "v1", Kind: "Secret", Name: "foo"}
+	b := naisiov1.ResourceReference{Namespace: "b", APIVersion: "v1", Kind: "Secret", Name: "foo"}
+	bar := naisiov1.ResourceReference{Namespace: "b", APIVersion: "v1", Kind: "Secret", Name: "bar"}
+
+	assert.Equal(t, []naisiov1.ResourceReference{b}, InNamespace([]naisiov1.ResourceReference{a, b}, "b"))
+	assert.Equal(t, []naisiov1.ResourceReference{a, bar}, ReplaceNamespace([]naisiov1.ResourceReference{b, a}, "b", []naisiov1.ResourceReference{bar}))
+	assert.Equal(t, []naisiov1.ResourceReference{a}, ReplaceNamespace([]naisiov1.ResourceReference{a, b}, "b", nil))
+}
+
+func TestHash(t *testing.T) {
+	spec := &naisiov1.ReplicationConfigSpec{
+		TemplateValues: naisiov1.TemplateValues{Values: map[string]string{"foo": "bar"}},
+	}
+
+	hash, err := Hash(spec, map[string]string{"apikey": "old"})
+	assert.NoError(t, err)
+
+	same, err := Hash(spec, map[string]string{"apikey": "old"})
+	assert.NoError(t, err)
+	assert.Equal(t, hash, same)
+
+	rotated, err := Hash(spec, map[string]string{"apikey": "new"})
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, rotated)
+}
+
+func TestRolloutOrder(t *testing.T) {
+	namespaces := []v1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "d"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "c", Labels: map[string]string{"canary": "true"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "b"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "a", Labels: map[string]string{"canary": "true"}}},
+	}
+
+	order, canaries := RolloutOrder(namespaces, labels.SelectorFromSet(labels.Set{"canary": "true"}))
+	assert.Equal(t, []string{"a", "c", "b", "d"}, order)
+	assert.Equal(t, 2, canaries)
+
+	order, canaries = RolloutOrder(namespaces, labels.Nothing())
+	assert.Equal(t, []string{"a", "b", "c", "d"}, order)
+	assert.Equal(t, 0, canaries)
+}
+
+func TestNextWave(t *testing.T) {
+	order := []string{"a", "c", "b", "d", "e"}
+
+	assert.Equal(t, []string{"a", "c"}, NextWave(order, 2, nil, 2))
+	assert.Equal(t, []string{"c"}, NextWave(order, 2, []string{"a"}, 2))
+	assert.Equal(t, []string{"b", "d"}, NextWave(order, 2, []string{"a", "c"}, 2))
+	assert.Equal(t, []string{"e"}, NextWave(order, 2, []string{"a", "c", "b", "d"}, 2))
+	assert.Empty(t, NextWave(order, 2, order, 2))
+	assert.Equal(t, []string{"a", "c", "b"}, NextWave(order, 0, nil, 3))
+}
+
+func TestForNamespace(t *testing.T) {
+	resources := []naisiov1.Resource{
+		{Template: "all"},
+		{Template: "prod", NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}}},
+	}
+
+	selected, err := ForNamespace(resources, v1.Namespace{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"env": "prod"}}})
+	assert.NoError(t, err)
+	assert.Len(t, selected, 2)
+
+	selected, err = ForNamespace(resources, v1.Namespace{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"env": "dev"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, []naisiov1.Resource{{Template: "all"}}, selected)
+}
+
+func TestRenderResourcesWhen(t *testing.T) {
+	template := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: [[ .Values.name ]]
+`
+	resources := []naisiov1.Resource{
+		{Template: template, When: `[[ eq .Values.env "prod" ]]`},
+		{Template: template},
+	}
+
+	objects, err := RenderResources(&TemplateValues{Values: map[string]string{"env": "dev", "name": "foo"}}, resources)
+	assert.NoError(t, err)
+	assert.Len(t, objects, 1)
+
+	objects, err = RenderResources(&TemplateValues{Values: map[string]string{"env": "prod", "name": "foo"}}, resources)
+	assert.NoError(t, err)
+	assert.Len(t, objects, 2)
+}
+
+func TestRenderResourcesNamespace(t *testing.T) {
+	rc := &naisiov1.ReplicationConfig{ObjectMeta: metav1.ObjectMeta{Name: "config"}}
+	ns := v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "team",
+		Labels:      map[string]string{"a.io/team": "a", "b.io/team": "b"},
+		Annotations: map[string]string{"owner": "someone"},
+	}}
+	resources := []naisiov1.Resource{{Template: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: [[ .Config.Name ]]-[[ .Namespace.Name ]]
+data:
+  a: [[ index .Namespace.Labels "a.io/team" ]]
+  b: [[ index .Namespace.Labels "b.io/team" ]]
+  owner: [[ .Namespace.Annotations.owner ]]
+`}}
+
+	objects, err := RenderResources(NewTemplateValues(rc, ns, map[string]string{}), resources)
+	assert.NoError(t, err)
+	assert.Len(t, objects, 1)
+	assert.Equal(t, "config-team", objects[0].GetName())
+	assert.Equal(t, map[string]any{"a": "a", "b": "b", "owner": "someone"}, objects[0].Object["data"])
+}
+
+func TestScopePrecedence(t *testing.T) {
+	spec := map[string]string{"a": "spec", "b": "spec", "c": "spec"}
+	secrets := map[string]string{"b": "secret", "c": "secret"}
+	namespace := map[string]string{"c": "namespace"}
+
+	values := NewScope(spec, secrets).With(namespace).Resolve()
+	assert.Equal(t, map[string]string{"a": "spec", "b": "secret", "c": "namespace"}, values)
+}
+
+func TestScopeNoLeakage(t *testing.T) {
+	spec := map[string]string{"shared": "spec"}
+	secrets := map[string]string{"secret": "value"}
+	scope := NewScope(spec, secrets)
+
+	nsValues := naisiov1.Namespace{Labels: []string{"example.com/team"}}
+	a := v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "a", Labels: map[string]string{"example.com/team": "a"}}}
+	b := v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "b"}}
+
+	valuesA := scope.With(ExtractValues(a, nsValues)).Resolve()
+	valuesB := scope.With(ExtractValues(b, nsValues)).Resolve()
+
+	assert.Equal(t, "a", valuesA["team"])
+	assert.NotContains(t, valuesB, "team")
+	assert.Equal(t, map[string]string{"shared": "spec", "secret": "value"}, valuesB)
+
+	// the layers are left untouched
+	assert.Equal(t, map[string]string{"shared": "spec"}, spec)
+	assert.Equal(t, map[string]string{"secret": "value"}, secrets)
+	assert.Equal(t, map[string]string{"shared": "spec", "secret": "value"}, scope.Resolve())
+
+	// modifying resolved values does not change the scope
+	valuesB["shared"] = "changed"
+	assert.Equal(t, "spec", scope.Resolve()["shared"])
+}
+
+func TestLoadConfigMaps(t *testing.T) {
+	t.Setenv("POD_NAMESPACE", "replicator")
+	c := fake.NewClientBuilder().WithObjects(
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "first", Namespace: "replicator"}, Data: map[string]string{"project": "first", "cluster": "dev"}},
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "second", Namespace: "replicator"}, Data: map[string]string{"project": "second"}},
+	).Build()
+
+	rc := &naisiov1.ReplicationConfig{Spec: naisiov1.ReplicationConfigSpec{TemplateValues: naisiov1.TemplateValues{
+		ConfigMaps: []naisiov1.ConfigMap{{Name: "first"}, {Name: "second"}},
+	}}}
+	sources, err := LoadConfigMaps(context.Background(), c, rc)
+	assert.NoError(t, err)
+	assert.Equal(t, []Source{
+		{Name: "configMap/first", Values: map[string]string{"project": "first", "cluster": "dev"}},
+		{Name: "configMap/second", Values: map[string]string{"project": "second"}},
+	}, sources)
+
+	rc.Spec.TemplateValues.ConfigMaps = append(rc.Spec.TemplateValues.ConfigMaps, naisiov1.ConfigMap{Name: "missing"})
+	_, err = LoadConfigMaps(context.Background(), c, rc)
+	assert.Error(t, err)
+}
+
+func TestSecretValues(t *testing.T) {
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db"},
+		Data:       map[string][]byte{"username": []byte("app"), "password": []byte("secret")},
+	}
+
+	values, err := SecretValues(secret, naisiov1.Secret{Name: "db"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"username": "app", "password": "secret"}, values)
+
+	values, err = SecretValues(secret, naisiov1.Secret{Name: "db", Prefix: "db_"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"db_username": "app", "db_password": "secret"}, values)
+
+	values, err = SecretValues(secret, naisiov1.Secret{Name: "db", Prefix: "db_", Keys: []naisiov1.SecretKey{{Key: "password", As: "pass"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"db_pass": "secret"}, values)
+
+	_, err = SecretValues(secret, naisiov1.Secret{Name: "db", Keys: []naisiov1.SecretKey{{Key: "missing"}}})
+	assert.Error(t, err)
+
+	_, err = SecretValues(secret, naisiov1.Secret{Name: "db", Keys: []naisiov1.SecretKey{{Key: "username", As: "value"}, {Key: "password", As: "value"}}})
+	assert.Error(t, err)
+}
+
+func TestMergeValues(t *testing.T) {
+	t.Setenv("POD_NAMESPACE", "replicator")
+	c := fake.NewClientBuilder().WithObjects(
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "shared", Namespace: "replicator"}, Data: map[string]string{"project": "shared", "cluster": "dev"}},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "first", Namespace: "replicator"}, Data: map[string][]byte{"password": []byte("first"), "user": []byte("first")}},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "second", Namespace: "replicator"}, Data: map[string][]byte{"password": []byte("second")}},
+	).Build()
+
+	rc := &naisiov1.ReplicationConfig{Spec: naisiov1.ReplicationConfigSpec{TemplateValues: naisiov1.TemplateValues{
+		Values:     map[string]string{"project": "spec", "team": "spec"},
+		ConfigMaps: []naisiov1.ConfigMap{{Name: "shared"}},
+		Secrets:    []naisiov1.Secret{{Name: "first"}, {Name: "second"}},
+	}}}
+	load := func() (map[string]string, []naisiov1.ValueCollision) {
+		configMaps, err := LoadConfigMaps(context.Background(), c, rc)
+		assert.NoError(t, err)
+		secrets, err := LoadSecrets(context.Background(), c, rc)
+		assert.NoError(t, err)
+		return MergeValues(rc, configMaps, secrets)
+	}
+
+	values, collisions := load()
+	assert.Equal(t, map[string]string{"project": "shared", "team": "spec", "cluster": "dev", "password": "second", "user": "first"}, values)
+	assert.Equal(t, []naisiov1.ValueCollision{
+		{Key: "password", Sources: []string{"secret/first", "secret/second"}},
+		{Key: "project", Sources: []string{"values", "configMap/shared"}},
+	}, collisions)
+
+	rc.Spec.TemplateValues.Values = nil
+	rc.Spec.TemplateValues.Secrets[1].Prefix = "second_"
+	values, collisions = load()
+	assert.Equal(t, map[string]string{"project": "shared", "cluster": "dev", "password": "first", "second_password": "second", "user": "first"}, values)
+	assert.Empty(t, collisions)
+}
@@ -0,0 +1,48 @@
+package replicator
+
+import (
+	"slices"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// RolloutOrder returns the names of the namespaces in the order a change is rolled out to them:
+// the namespaces matching canary first, then the others, each sorted by name. The number of canaries is returned along with it.
+func RolloutOrder(namespaces []v1.Namespace, canary labels.Selector) ([]string, int) {
+	var canaries, others []string
+	for _, ns := range namespaces {
+		if canary.Matches(labels.Set(ns.Labels)) {
+			canaries = append(canaries, ns.Name)
+		} else {
+			others = append(others, ns.Name)
+		}
+	}
+	slices.Sort(canaries)
+	slices.Sort(others)
+	return append(canaries, others...), len(canaries)
+}
+
+// NextWave returns the namespaces of the next wave, skipping the ones in done.
+// The canaries, the first in order, form a wave of their own, and the others are rolled out batchSize at a time.
+func NextWave(order []string, canaries int, done []string, batchSize int) []string {
+	var wave []string
+	for _, name := range order[:canaries] {
+		if !slices.Contains(done, name) {
+			wave = append(wave, name)
+		}
+	}
+	if len(wave) > 0 {
+		return wave
+	}
+
+	for _, name := range order[canaries:] {
+		if len(wave) >= batchSize {
+			break
+		}
+		if !slices.Contains(done, name) {
+			wave = append(wave, name)
+		}
+	}
+	return wave
+}
@@ -0,0 +1,34 @@
+package replicator
+
+// Scope resolves template values from layers, where a key in a later layer takes precedence over the same key in an earlier one.
+// The values of a ReplicationConfig are layered as: spec.templateValues.values < config maps < secrets < namespace labels and annotations.
+//
+// A scope never modifies its layers, and adding a layer returns a new scope, so a scope shared by several namespaces
+// can not leak the values of one namespace into another.
+type Scope struct {
+	layers []map[string]string
+}
+
+// NewScope returns a scope of the layers, from lowest to highest precedence.
+func NewScope(layers ...map[string]string) Scope {
+	return Scope{}.With(layers...)
+}
+
+// With returns a new scope with the layers on top of the layers of s, which is left unchanged.
+func (s Scope) With(layers ...map[string]string) Scope {
+	combined := make([]map[string]string, 0, len(s.layers)+len(layers))
+	combined = append(combined, s.layers...)
+	combined = append(combined, layers...)
+	return Scope{layers: combined}
+}
+
+// Resolve returns the values of all layers in a new map.
+func (s Scope) Resolve() map[string]string {
+	values := make(map[string]string)
+	for _, layer := range s.layers {
+		for k, v := range layer {
+			values[k] = v
+		}
+	}
+	return values
+}
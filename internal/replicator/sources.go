@@ -0,0 +1,131 @@
+package replicator
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	naisiov1 "nais/replicator/api/v1"
+
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Source is the values loaded from one place, named in the collisions it is part of.
+type Source struct {
+	Name   string
+	Values map[string]string
+}
+
+// SpecSource returns the values set directly in the ReplicationConfig.
+func SpecSource(rc *naisiov1.ReplicationConfig) Source {
+	return Source{Name: "values", Values: rc.Spec.TemplateValues.Values}
+}
+
+// ConfigMapSource returns the values of a config map.
+func ConfigMapSource(configMap *v1.ConfigMap) Source {
+	return Source{Name: "configMap/" + configMap.Name, Values: configMap.Data}
+}
+
+// SecretSource returns the values of the secret, selected, renamed and prefixed as configured by ref.
+func SecretSource(secret *v1.Secret, ref naisiov1.Secret) (Source, error) {
+	values, err := SecretValues(secret, ref)
+	if err != nil {
+		return Source{}, err
+	}
+	return Source{Name: "secret/" + secret.Name, Values: values}, nil
+}
+
+// LoadConfigMaps loads the config maps referenced by the ReplicationConfig, in order.
+func LoadConfigMaps(ctx context.Context, c client.Client, rc *naisiov1.ReplicationConfig) ([]Source, error) {
+	sources := make([]Source, 0, len(rc.Spec.TemplateValues.ConfigMaps))
+	for _, cm := range rc.Spec.TemplateValues.ConfigMaps {
+		var configMap v1.ConfigMap
+		if err := c.Get(ctx, client.ObjectKey{Name: cm.Name, Namespace: os.Getenv("POD_NAMESPACE")}, &configMap); err != nil {
+			return nil, err
+		}
+		sources = append(sources, ConfigMapSource(&configMap))
+	}
+	return sources, nil
+}
+
+// LoadSecrets loads the secrets referenced by the ReplicationConfig, in order.
+func LoadSecrets(ctx context.Context, c client.Client, rc *naisiov1.ReplicationConfig) ([]Source, error) {
+	sources := make([]Source, 0, len(rc.Spec.TemplateValues.Secrets))
+	for _, s := range rc.Spec.TemplateValues.Secrets {
+		var secret v1.Secret
+		if err := c.Get(ctx, client.ObjectKey{Name: s.Name, Namespace: os.Getenv("POD_NAMESPACE")}, &secret); err != nil {
+			return nil, err
+		}
+
+		source, err := SecretSource(&secret, s)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+// MergeValues merges the values set in the ReplicationConfig with the loaded config maps and secrets, in order of precedence:
+// values in the spec, then config maps, then secrets. The values set by more than one of them are returned as collisions.
+func MergeValues(rc *naisiov1.ReplicationConfig, configMaps, secrets []Source) (map[string]string, []naisiov1.ValueCollision) {
+	sources := append([]Source{SpecSource(rc)}, configMaps...)
+	return MergeSources(append(sources, secrets...))
+}
+
+// SecretValues returns the values of the secret, selected, renamed and prefixed as configured by ref.
+func SecretValues(secret *v1.Secret, ref naisiov1.Secret) (map[string]string, error) {
+	values := make(map[string]string)
+	if len(ref.Keys) == 0 {
+		for k, v := range secret.Data {
+			values[ref.Prefix+k] = string(v)
+		}
+		return values, nil
+	}
+
+	loadedFrom := make(map[string]string, len(ref.Keys))
+	for _, key := range ref.Keys {
+		v, ok := secret.Data[key.Key]
+		if !ok {
+			return nil, fmt.Errorf("key %q not found in secret %q", key.Key, secret.Name)
+		}
+
+		name := key.As
+		if name == "" {
+			name = key.Key
+		}
+		name = ref.Prefix + name
+		if other, ok := loadedFrom[name]; ok && other != key.Key {
+			return nil, fmt.Errorf("keys %q and %q of secret %q are both loaded as %q", other, key.Key, secret.Name, name)
+		}
+		loadedFrom[name] = key.Key
+		values[name] = string(v)
+	}
+	return values, nil
+}
+
+// MergeSources merges the values of the sources in order, where a later source takes precedence.
+// The values set by more than one source are returned as collisions, sorted by key.
+func MergeSources(sources []Source) (map[string]string, []naisiov1.ValueCollision) {
+	values := make(map[string]string)
+	setBy := make(map[string][]string)
+	for _, source := range sources {
+		for k, v := range source.Values {
+			values[k] = v
+			setBy[k] = append(setBy[k], source.Name)
+		}
+	}
+
+	var collisions []naisiov1.ValueCollision
+	for k, names := range setBy {
+		if len(names) > 1 {
+			collisions = append(collisions, naisiov1.ValueCollision{Key: k, Sources: names})
+		}
+	}
+	sort.Slice(collisions, func(i, j int) bool {
+		return collisions[i].Key < collisions[j].Key
+	})
+	return values, collisions
+}
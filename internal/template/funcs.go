@@ -0,0 +1,461 @@
+package template
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"gopkg.in/yaml.v2"
+)
+
+// funcs is the function library available in templates. It follows the names and argument order of Sprig,
+// so the piped value is the last argument, but only has deterministic functions: templates are rendered on every
+// synchronization, and a function returning a new value each time would change the resources every time.
+func funcs() template.FuncMap {
+	return template.FuncMap{
+		// defaults and checks
+		"default":  defaultValue,
+		"empty":    empty,
+		"coalesce": coalesce,
+		"required": required,
+		"ternary":  ternary,
+
+		// strings
+		"toString":   toString,
+		"lower":      func(s any) string { return strings.ToLower(toString(s)) },
+		"upper":      func(s any) string { return strings.ToUpper(toString(s)) },
+		"title":      title,
+		"trim":       func(s any) string { return strings.TrimSpace(toString(s)) },
+		"trimAll":    func(cutset string, s any) string { return strings.Trim(toString(s), cutset) },
+		"trimPrefix": func(prefix string, s any) string { return strings.TrimPrefix(toString(s), prefix) },
+		"trimSuffix": func(suffix string, s any) string { return strings.TrimSuffix(toString(s), suffix) },
+		"replace":    func(old, new string, s any) string { return strings.ReplaceAll(toString(s), old, new) },
+		"contains":   func(substr string, s any) bool { return strings.Contains(toString(s), substr) },
+		"hasPrefix":  func(prefix string, s any) bool { return strings.HasPrefix(toString(s), prefix) },
+		"hasSuffix":  func(suffix string, s any) bool { return strings.HasSuffix(toString(s), suffix) },
+		"repeat":     func(count int, s any) string { return strings.Repeat(toString(s), max(count, 0)) },
+		"substr":     substr,
+		"trunc":      trunc,
+		"nospace":    nospace,
+		"quote":      quote,
+		"squote":     squote,
+		"cat":        cat,
+		"indent":     indent,
+		"nindent":    func(spaces int, s any) string { return "\n" + indent(spaces, s) },
+		"splitList":  func(sep string, s any) []string { return strings.Split(toString(s), sep) },
+		"join":       join,
+
+		// regular expressions
+		"regexMatch":      regexMatch,
+		"regexReplaceAll": regexReplaceAll,
+
+		// encoding
+		"b64enc":    b64enc,
+		"b64dec":    b64dec,
+		"sha1sum":   func(s any) string { sum := sha1.Sum([]byte(toString(s))); return hex.EncodeToString(sum[:]) },
+		"sha256sum": func(s any) string { sum := sha256.Sum256([]byte(toString(s))); return hex.EncodeToString(sum[:]) },
+		"toJson":    toJSON,
+		"toYaml":    toYAML,
+
+		// lists and dictionaries
+		"list":   func(items ...any) []any { return items },
+		"first":  first,
+		"last":   last,
+		"has":    has,
+		"dict":   dict,
+		"get":    get,
+		"hasKey": hasKey,
+		"keys":   keys,
+
+		// numbers
+		"int": toInt,
+		"add": arithmetic(func(a, b int64) (int64, error) { return a + b, nil }),
+		"sub": arithmetic(func(a, b int64) (int64, error) { return a - b, nil }),
+		"mul": arithmetic(func(a, b int64) (int64, error) { return a * b, nil }),
+		"div": arithmetic(func(a, b int64) (int64, error) {
+			if b == 0 {
+				return 0, errors.New("division by zero")
+			}
+			return a / b, nil
+		}),
+		"mod": arithmetic(func(a, b int64) (int64, error) {
+			if b == 0 {
+				return 0, errors.New("division by zero")
+			}
+			return a % b, nil
+		}),
+		"max": arithmetic(func(a, b int64) (int64, error) { return max(a, b), nil }),
+		"min": arithmetic(func(a, b int64) (int64, error) { return min(a, b), nil }),
+	}
+}
+
+// empty reports whether the value is nil, false, zero, or an empty string, list or dictionary.
+func empty(v any) bool {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return true
+	}
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return rv.IsNil()
+	default:
+		return rv.IsZero()
+	}
+}
+
+func defaultValue(def any, given ...any) any {
+	if len(given) == 0 || empty(given[0]) {
+		return def
+	}
+	return given[0]
+}
+
+func coalesce(values ...any) any {
+	for _, v := range values {
+		if !empty(v) {
+			return v
+		}
+	}
+	return nil
+}
+
+func required(message string, v any) (any, error) {
+	if empty(v) {
+		return nil, errors.New(message)
+	}
+	return v, nil
+}
+
+func ternary(whenTrue, whenFalse any, condition bool) any {
+	if condition {
+		return whenTrue
+	}
+	return whenFalse
+}
+
+func toString(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case []byte:
+		return string(t)
+	case error:
+		return t.Error()
+	case fmt.Stringer:
+		return t.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// title upper cases the first letter of each word.
+func title(s any) string {
+	runes := []rune(toString(s))
+	for i, r := range runes {
+		if i == 0 || unicode.IsSpace(runes[i-1]) {
+			runes[i] = unicode.ToUpper(r)
+		}
+	}
+	return string(runes)
+}
+
+// substr returns the bytes from start to end, where a negative end is the end of the string.
+func substr(start, end int, s any) string {
+	str := toString(s)
+	start = min(max(start, 0), len(str))
+	if end < 0 || end > len(str) {
+		end = len(str)
+	}
+	if start > end {
+		return ""
+	}
+	return str[start:end]
+}
+
+// trunc keeps the first n bytes of the string, or the last n if n is negative.
+func trunc(n int, s any) string {
+	str := toString(s)
+	if n >= 0 {
+		return str[:min(n, len(str))]
+	}
+	return str[max(len(str)+n, 0):]
+}
+
+func nospace(s any) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, toString(s))
+}
+
+func quote(values ...any) string {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != nil {
+			quoted = append(quoted, strconv.Quote(toString(v)))
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+func squote(values ...any) string {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != nil {
+			quoted = append(quoted, "'"+toString(v)+"'")
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+func cat(values ...any) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != nil {
+			parts = append(parts, toString(v))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// indent prefixes every line of the string with the number of spaces.
+func indent(spaces int, s any) string {
+	pad := strings.Repeat(" ", max(spaces, 0))
+	return pad + strings.ReplaceAll(toString(s), "\n", "\n"+pad)
+}
+
+func join(sep string, list any) (string, error) {
+	items, err := toList(list)
+	if err != nil {
+		return "", err
+	}
+	parts := make([]string, 0, len(items))
+	for _, item := range items {
+		parts = append(parts, toString(item))
+	}
+	return strings.Join(parts, sep), nil
+}
+
+func regexMatch(expr string, s any) (bool, error) {
+	return regexp.MatchString(expr, toString(s))
+}
+
+func regexReplaceAll(expr string, s any, replacement string) (string, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return "", err
+	}
+	return re.ReplaceAllString(toString(s), replacement), nil
+}
+
+func b64enc(s any) string {
+	return base64.StdEncoding.EncodeToString([]byte(toString(s)))
+}
+
+func b64dec(s any) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(toString(s))
+	if err != nil {
+		return "", fmt.Errorf("b64dec: %w", err)
+	}
+	return string(b), nil
+}
+
+func toJSON(v any) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("toJson: %w", err)
+	}
+	return string(b), nil
+}
+
+func toYAML(v any) (string, error) {
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("toYaml: %w", err)
+	}
+	return strings.TrimSuffix(string(b), "\n"), nil
+}
+
+// toList returns the items of any slice or array.
+func toList(list any) ([]any, error) {
+	rv := reflect.ValueOf(list)
+	if !rv.IsValid() {
+		return nil, nil
+	}
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("expected a list, got %T", list)
+	}
+	items := make([]any, rv.Len())
+	for i := range items {
+		items[i] = rv.Index(i).Interface()
+	}
+	return items, nil
+}
+
+func first(list any) (any, error) {
+	items, err := toList(list)
+	if err != nil || len(items) == 0 {
+		return nil, err
+	}
+	return items[0], nil
+}
+
+func last(list any) (any, error) {
+	items, err := toList(list)
+	if err != nil || len(items) == 0 {
+		return nil, err
+	}
+	return items[len(items)-1], nil
+}
+
+func has(needle, list any) (bool, error) {
+	items, err := toList(list)
+	if err != nil {
+		return false, err
+	}
+	for _, item := range items {
+		if reflect.DeepEqual(item, needle) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// dict builds a dictionary from pairs of keys and values. A key without a value gets an empty string.
+func dict(pairs ...any) map[string]any {
+	d := make(map[string]any, (len(pairs)+1)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		var value any = ""
+		if i+1 < len(pairs) {
+			value = pairs[i+1]
+		}
+		d[toString(pairs[i])] = value
+	}
+	return d
+}
+
+// toMap returns the entries of any dictionary with string keys, like the values or a dict.
+func toMap(d any) (map[string]any, error) {
+	rv := reflect.ValueOf(d)
+	if !rv.IsValid() {
+		return nil, nil
+	}
+	if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+		return nil, fmt.Errorf("expected a dictionary, got %T", d)
+	}
+	m := make(map[string]any, rv.Len())
+	for _, key := range rv.MapKeys() {
+		m[key.String()] = rv.MapIndex(key).Interface()
+	}
+	return m, nil
+}
+
+func get(d any, key string) (any, error) {
+	m, err := toMap(d)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := m[key]
+	if !ok {
+		return "", nil
+	}
+	return v, nil
+}
+
+func hasKey(d any, key string) (bool, error) {
+	m, err := toMap(d)
+	if err != nil {
+		return false, err
+	}
+	_, ok := m[key]
+	return ok, nil
+}
+
+// keys returns the sorted keys of the dictionaries, so the output does not change between renders.
+func keys(dicts ...any) ([]string, error) {
+	var all []string
+	for _, d := range dicts {
+		m, err := toMap(d)
+		if err != nil {
+			return nil, err
+		}
+		for key := range m {
+			all = append(all, key)
+		}
+	}
+	sort.Strings(all)
+	return all, nil
+}
+
+// toInt64 converts numbers and numeric strings, as all template values are strings.
+func toInt64(v any) (int64, error) {
+	switch t := v.(type) {
+	case string:
+		if i, err := strconv.ParseInt(strings.TrimSpace(t), 10, 64); err == nil {
+			return i, nil
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(t), 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a number", t)
+		}
+		return int64(f), nil
+	case bool:
+		if t {
+			return 1, nil
+		}
+		return 0, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return int64(rv.Float()), nil
+	}
+	return 0, fmt.Errorf("%v of type %T is not a number", v, v)
+}
+
+func toInt(v any) (int, error) {
+	i, err := toInt64(v)
+	return int(i), err
+}
+
+// arithmetic applies op to the numbers from left to right.
+func arithmetic(op func(a, b int64) (int64, error)) func(a any, rest ...any) (int64, error) {
+	return func(a any, rest ...any) (int64, error) {
+		result, err := toInt64(a)
+		if err != nil {
+			return 0, err
+		}
+		for _, v := range rest {
+			b, err := toInt64(v)
+			if err != nil {
+				return 0, err
+			}
+			if result, err = op(result, b); err != nil {
+				return 0, err
+			}
+		}
+		return result, nil
+	}
+}
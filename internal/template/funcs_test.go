@@ -0,0 +1,105 @@
+package template
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFuncs(t *testing.T) {
+	values := TemplateValues{Values: map[string]string{
+		"name":  "My App",
+		"empty": "",
+		"count": "3",
+		"b64":   "aGVsbG8=",
+		"csv":   "a,b,c",
+	}}
+
+	for _, tc := range []struct {
+		name string
+		tpl  string
+		want string
+	}{
+		{"default", `[[ .Values.empty | default "fallback" ]] [[ .Values.name | default "fallback" ]]`, "fallback My App"},
+		{"empty", `[[ empty .Values.empty ]] [[ empty .Values.name ]] [[ empty 0 ]] [[ empty (list) ]]`, "true false true true"},
+		{"coalesce", `[[ coalesce .Values.empty "" "second" "third" ]]`, "second"},
+		{"required", `[[ required "name is required" .Values.name ]]`, "My App"},
+		{"ternary", `[[ ternary "yes" "no" true ]] [[ ternary "yes" "no" false ]]`, "yes no"},
+		{"toString", `[[ toString 42 ]]`, "42"},
+		{"lower", `[[ .Values.name | lower ]]`, "my app"},
+		{"upper", `[[ .Values.name | upper ]]`, "MY APP"},
+		{"title", `[[ "hello big world" | title ]]`, "Hello Big World"},
+		{"trim", `[[ "  padded  " | trim ]]`, "padded"},
+		{"trimAll", `[[ "--name--" | trimAll "-" ]]`, "name"},
+		{"trimPrefix", `[[ "team-a" | trimPrefix "team-" ]]`, "a"},
+		{"trimSuffix", `[[ "app-prod" | trimSuffix "-prod" ]]`, "app"},
+		{"replace", `[[ .Values.name | replace " " "-" ]]`, "My-App"},
+		{"contains", `[[ .Values.name | contains "App" ]] [[ .Values.name | contains "x" ]]`, "true false"},
+		{"hasPrefix", `[[ .Values.name | hasPrefix "My" ]]`, "true"},
+		{"hasSuffix", `[[ .Values.name | hasSuffix "My" ]]`, "false"},
+		{"repeat", `[[ "ab" | repeat 3 ]]`, "ababab"},
+		{"substr", `[[ "abcdef" | substr 1 3 ]] [[ "abcdef" | substr 2 -1 ]]`, "bc cdef"},
+		{"trunc", `[[ "abcdef" | trunc 3 ]] [[ "abcdef" | trunc -2 ]] [[ "ab" | trunc 5 ]]`, "abc ef ab"},
+		{"nospace", `[[ .Values.name | nospace ]]`, "MyApp"},
+		{"quote", `[[ .Values.name | quote ]] [[ quote "a" "b" ]]`, `"My App" "a" "b"`},
+		{"squote", `[[ .Values.name | squote ]]`, "'My App'"},
+		{"cat", `[[ cat "a" "b" 1 ]]`, "a b 1"},
+		{"indent", `[[ "a\nb" | indent 2 ]]`, "  a\n  b"},
+		{"nindent", `x:[[ "a\nb" | nindent 2 ]]`, "x:\n  a\n  b"},
+		{"splitList", `[[ range splitList "," .Values.csv ]][[ . ]];[[ end ]]`, "a;b;c;"},
+		{"join", `[[ splitList "," .Values.csv | join "-" ]] [[ list 1 2 | join "+" ]]`, "a-b-c 1+2"},
+		{"regexMatch", `[[ regexMatch "^[a-z]+$" "abc" ]] [[ regexMatch "^[a-z]+$" .Values.name ]]`, "true false"},
+		{"regexReplaceAll", `[[ regexReplaceAll "[^a-z]+" "My App" "-" ]]`, "-y-pp"},
+		{"b64enc", `[[ "hello" | b64enc ]]`, "aGVsbG8="},
+		{"b64dec", `[[ .Values.b64 | b64dec ]]`, "hello"},
+		{"sha1sum", `[[ "hello" | sha1sum ]]`, "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d"},
+		{"sha256sum", `[[ "hello" | sha256sum ]]`, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"},
+		{"toJson", `[[ dict "b" 1 "a" "x" | toJson ]]`, `{"a":"x","b":1}`},
+		{"toYaml", `[[ dict "b" 1 "a" "x" | toYaml ]]`, "a: x\nb: 1"},
+		{"list", `[[ list "a" 1 | len ]]`, "2"},
+		{"first", `[[ splitList "," .Values.csv | first ]]`, "a"},
+		{"last", `[[ splitList "," .Values.csv | last ]]`, "c"},
+		{"has", `[[ splitList "," .Values.csv | has "b" ]] [[ list 1 2 | has 3 ]]`, "true false"},
+		{"dict", `[[ $d := dict "key" "value" "alone" ]][[ $d.key ]]-[[ $d.alone ]]`, "value-"},
+		{"get", `[[ get .Values "name" ]]|[[ get .Values "missing" ]]`, "My App|"},
+		{"hasKey", `[[ hasKey .Values "name" ]] [[ hasKey .Values "missing" ]]`, "true false"},
+		{"keys", `[[ keys (dict "b" 1 "a" 2) (dict "c" 3) | join "," ]]`, "a,b,c"},
+		{"int", `[[ int .Values.count | printf "%T" ]]`, "int"},
+		{"add", `[[ add .Values.count 2 1 ]]`, "6"},
+		{"sub", `[[ sub .Values.count 1 ]]`, "2"},
+		{"mul", `[[ mul .Values.count 2 ]]`, "6"},
+		{"div", `[[ div 7 .Values.count ]]`, "2"},
+		{"mod", `[[ mod 7 .Values.count ]]`, "1"},
+		{"max", `[[ max 1 .Values.count 2 ]]`, "3"},
+		{"min", `[[ min 5 .Values.count 4 ]]`, "3"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := renderString(values, tc.tpl)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestFuncsErrors(t *testing.T) {
+	values := TemplateValues{Values: map[string]string{"empty": "", "text": "abc"}}
+
+	for _, tc := range []struct {
+		name string
+		tpl  string
+	}{
+		{"required", `[[ required "value is required" .Values.empty ]]`},
+		{"b64dec", `[[ "not base64!" | b64dec ]]`},
+		{"regexMatch", `[[ regexMatch "(" "abc" ]]`},
+		{"join", `[[ join "," "not a list" ]]`},
+		{"get", `[[ get "not a dict" "key" ]]`},
+		{"add", `[[ add .Values.text 1 ]]`},
+		{"div", `[[ div 1 0 ]]`},
+		{"mod", `[[ mod 1 0 ]]`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := renderString(values, tc.tpl)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -2,7 +2,12 @@ package template
 
 import (
 	"bytes"
-	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
 	"text/template"
 
 	"gopkg.in/yaml.v2"
@@ -17,7 +22,9 @@ func WithOption(option string) RenderOption {
 	}
 }
 
-func RenderTemplate(values any, tpl string, options ...RenderOption) (*unstructured.Unstructured, error) {
+// RenderTemplate renders the template into the objects of its YAML documents, separated by "---".
+// Empty documents are skipped, and the items of a List kind are returned as separate objects.
+func RenderTemplate(values any, tpl string, options ...RenderOption) ([]*unstructured.Unstructured, error) {
 	if options == nil {
 		options = []RenderOption{WithOption("missingkey=error")}
 	}
@@ -27,17 +34,84 @@ func RenderTemplate(values any, tpl string, options ...RenderOption) (*unstructu
 		return nil, err
 	}
 
-	var v any
-	if err := yaml.Unmarshal([]byte(rdr), &v); err != nil {
-		return nil, err
+	var objects []*unstructured.Unstructured
+	dec := yaml.NewDecoder(strings.NewReader(rdr))
+	for i := 0; ; i++ {
+		var v any
+		err := dec.Decode(&v)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %w", i, err)
+		}
+		if v == nil {
+			continue
+		}
+
+		object, ok := repairMapAny(v).(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("document %d: not an object", i)
+		}
+		u := &unstructured.Unstructured{Object: object}
+
+		if !isList(u) {
+			objects = append(objects, u)
+			continue
+		}
+		list, err := u.ToList()
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %w", i, err)
+		}
+		for j := range list.Items {
+			objects = append(objects, &list.Items[j])
+		}
+	}
+	return objects, nil
+}
+
+// RenderCondition renders the template expression and reports whether it rendered true.
+// Anything other than true or false, ignoring surrounding whitespace, is an error.
+func RenderCondition(values any, expr string, options ...RenderOption) (bool, error) {
+	if options == nil {
+		options = []RenderOption{WithOption("missingkey=error")}
 	}
-	v = repairMapAny(v)
 
-	u := &unstructured.Unstructured{
-		Object: v.(map[string]interface{}),
+	rdr, err := renderString(values, expr, options...)
+	if err != nil {
+		return false, err
 	}
 
-	return u, nil
+	ok, err := strconv.ParseBool(strings.TrimSpace(rdr))
+	if err != nil {
+		return false, fmt.Errorf("condition rendered %q, not true or false", strings.TrimSpace(rdr))
+	}
+	return ok, nil
+}
+
+// Parse checks that the template is syntactically valid, without rendering it.
+func Parse(tpl string) error {
+	_, err := newTemplate().Parse(tpl)
+	return err
+}
+
+// funcErrorPattern matches the error text/template wraps the error returned by a function in.
+var funcErrorPattern = regexp.MustCompile(`error calling (\w+): `)
+
+// IsFuncError reports whether rendering failed because a function of the library returned an error, e.g. required
+// or int on a missing value, rather than because the template, its use of the data or the rendered YAML is invalid.
+func IsFuncError(err error) bool {
+	var execErr template.ExecError
+	if !errors.As(err, &execErr) {
+		return false
+	}
+	match := funcErrorPattern.FindStringSubmatch(execErr.Error())
+	return match != nil && funcs()[match[1]] != nil
+}
+
+// isList reports whether the object is a List kind, like v1/List or ConfigMapList, with its objects in items.
+func isList(u *unstructured.Unstructured) bool {
+	return strings.HasSuffix(u.GetKind(), "List") && u.IsList()
 }
 
 func repairMapAny(v any) any {
@@ -57,9 +131,7 @@ func repairMapAny(v any) any {
 }
 
 func renderString(values any, tpl string, tplOptions ...RenderOption) (string, error) {
-	t := template.New("tpl").Delims("[[", "]]").Funcs(template.FuncMap{
-		"b64enc": b64enc,
-	})
+	t := newTemplate()
 	for _, option := range tplOptions {
 		t = option(t)
 	}
@@ -75,7 +147,6 @@ func renderString(values any, tpl string, tplOptions ...RenderOption) (string, e
 	return buf.String(), nil
 }
 
-func b64enc(in any) string {
-	s, _ := in.(string)
-	return base64.StdEncoding.EncodeToString([]byte(s))
+func newTemplate() *template.Template {
+	return template.New("tpl").Delims("[[", "]]").Funcs(funcs())
 }
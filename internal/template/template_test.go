@@ -17,5 +17,78 @@ func TestRenderTemplateWithOptions(t *testing.T) {
 	assert.NoError(t, err)
 	u, err := RenderTemplate(TemplateValues{Values: map[string]string{"tommy.johnny": "foo"}}, string(file), WithOption("missingkey=invalid"))
 	assert.NoError(t, err)
-	fmt.Printf("map created: %v", u.Object)
+	assert.Len(t, u, 1)
+	fmt.Printf("map created: %v", u[0].Object)
+}
+
+func TestRenderTemplateMultipleDocuments(t *testing.T) {
+	tpl := `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: first
+---
+# only a comment
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: second
+---
+`
+	objects, err := RenderTemplate(TemplateValues{}, tpl)
+	assert.NoError(t, err)
+	assert.Len(t, objects, 2)
+	assert.Equal(t, "first", objects[0].GetName())
+	assert.Equal(t, "second", objects[1].GetName())
+}
+
+func TestRenderTemplateList(t *testing.T) {
+	tpl := `apiVersion: v1
+kind: List
+items:
+[[- range $group := .Groups ]]
+- apiVersion: rbac.authorization.k8s.io/v1
+  kind: RoleBinding
+  metadata:
+    name: [[ $group ]]
+[[- end ]]
+`
+	objects, err := RenderTemplate(struct{ Groups []string }{Groups: []string{"a", "b"}}, tpl)
+	assert.NoError(t, err)
+	assert.Len(t, objects, 2)
+	assert.Equal(t, "RoleBinding", objects[0].GetKind())
+	assert.Equal(t, "a", objects[0].GetName())
+	assert.Equal(t, "b", objects[1].GetName())
+}
+
+func TestRenderTemplateEmpty(t *testing.T) {
+	objects, err := RenderTemplate(TemplateValues{}, "---\n---\n")
+	assert.NoError(t, err)
+	assert.Empty(t, objects)
+}
+
+func TestRenderTemplateNotAnObject(t *testing.T) {
+	_, err := RenderTemplate(TemplateValues{}, "- a\n- b\n")
+	assert.Error(t, err)
+}
+
+func TestRenderCondition(t *testing.T) {
+	values := TemplateValues{Values: map[string]string{"env": "prod"}}
+
+	ok, err := RenderCondition(values, `[[ eq .Values.env "prod" ]]`)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = RenderCondition(values, ` [[ eq .Values.env "dev" ]] `)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = RenderCondition(values, `[[ .Values.env ]]`)
+	assert.Error(t, err)
+}
+
+func TestParse(t *testing.T) {
+	assert.NoError(t, Parse(`[[ eq .Values.env "prod" ]]`))
+	assert.Error(t, Parse(`[[ eq .Values.env "prod" `))
 }
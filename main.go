@@ -62,6 +62,10 @@ func main() {
 	var enableWebhooks bool
 	var debug bool
 	var interval time.Duration
+	var namespaceWorkers int
+	var maxConcurrentReconciles int
+	var kubeAPIQPS float64
+	var kubeAPIBurst int
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
@@ -70,6 +74,10 @@ func main() {
 	flag.BoolVar(&enableWebhooks, "enable-webhooks", true, "Enable webhooks")
 	flag.BoolVar(&debug, "debug", os.Getenv("DEBUG") == "true", "Enable debug logging")
 	flag.DurationVar(&interval, "sync-interval", 15*time.Minute, "Synchronization interval for reconciliation")
+	flag.IntVar(&namespaceWorkers, "namespace-workers", 10, "Number of namespaces synchronized at the same time by a ReplicationConfig")
+	flag.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 1, "Number of ReplicationConfigs reconciled at the same time")
+	flag.Float64Var(&kubeAPIQPS, "kube-api-qps", 20, "Queries per second to the Kubernetes API server")
+	flag.IntVar(&kubeAPIBurst, "kube-api-burst", 30, "Burst of queries to the Kubernetes API server")
 
 	opts := zap.Options{
 		Development: true,
@@ -88,7 +96,11 @@ func main() {
 		os.Exit(1)
 	}
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	config := ctrl.GetConfigOrDie()
+	config.QPS = float32(kubeAPIQPS)
+	config.Burst = kubeAPIBurst
+
+	mgr, err := ctrl.NewManager(config, ctrl.Options{
 		Scheme: scheme,
 		Metrics: metricsserver.Options{
 			BindAddress: metricsAddr,
@@ -109,10 +121,12 @@ func main() {
 	}
 
 	if err = (&controllers.ReplicationConfigReconciler{
-		Client:       mgr.GetClient(),
-		Scheme:       mgr.GetScheme(),
-		Recorder:     mgr.GetEventRecorderFor("replicator"),
-		SyncInterval: interval,
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		Recorder:                mgr.GetEventRecorderFor("replicator"),
+		SyncInterval:            interval,
+		NamespaceWorkers:        namespaceWorkers,
+		MaxConcurrentReconciles: maxConcurrentReconciles,
 	}).SetupWithManager(mgr); err != nil {
 		log.Errorf("unable to create controller %v", err)
 		os.Exit(1)